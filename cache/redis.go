@@ -65,6 +65,11 @@ func (rc *redisCache) do(commandName string, args ...interface{}) (reply interfa
 	return c.Do(commandName, args...)
 }
 
+// Pool returns the connection pool, nil until StartAndGC is called.
+func (rc *redisCache) Pool() *redis.Pool {
+	return rc.p
+}
+
 //SISMEMBER key member
 func (rc *redisCache)Sismember(key string,member interface{}) (int,error) {
 	return redis.Int(rc.do("SISMEMBER",key,member))
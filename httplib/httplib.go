@@ -7,9 +7,11 @@ package httplib
 
 import (
 	"bytes"
+	"context"
 	"crypto/tls"
 	"encoding/json"
 	"encoding/xml"
+	"github.com/widaT/golib/logger"
 	"io"
 	"io/ioutil"
 	"log"
@@ -193,6 +195,17 @@ func (r *Request) SetProtocolVersion(vers string) *Request {
 	return r
 }
 
+// WithContext sets the context of the request, which can cancel it. The
+// request ID carried by ctx, e.g. the context of a web/server request, is
+// forwarded in the X-Request-ID header unless it is already set.
+func (r *Request) WithContext(ctx context.Context) *Request {
+	r.req = r.req.WithContext(ctx)
+	if id := logger.RequestIDFromContext(ctx); id != "" && r.req.Header.Get(logger.RequestIDHeader) == "" {
+		r.req.Header.Set(logger.RequestIDHeader, id)
+	}
+	return r
+}
+
 // SetCookie add cookie into request.
 func (r *Request) SetCookie(cookie *http.Cookie) *Request {
 	r.req.Header.Add("Cookie", cookie.String())
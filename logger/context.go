@@ -0,0 +1,39 @@
+package logger
+
+import "context"
+
+// RequestIDHeader is the HTTP header carrying the request ID between
+// services.
+const RequestIDHeader = "X-Request-ID"
+
+type requestIDKey struct{}
+
+// NewRequestIDContext returns a copy of ctx carrying the request ID id.
+func NewRequestIDContext(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+// RequestIDFromContext returns the request ID carried by ctx, or an empty
+// string.
+func RequestIDFromContext(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// WithRequestID returns a logger writing to the same output as bl, which
+// tags every message with the request ID id. Closing it closes bl.
+func (bl *GxLogger) WithRequestID(id string) *GxLogger {
+	if id == "" {
+		return bl
+	}
+	return &GxLogger{level: bl.level, l: bl.l, prefix: bl.prefix + "[" + id + "] "}
+}
+
+// WithContext returns a logger tagging every message with the request ID
+// carried by ctx, or bl if there is none.
+func (bl *GxLogger) WithContext(ctx context.Context) *GxLogger {
+	return bl.WithRequestID(RequestIDFromContext(ctx))
+}
@@ -29,6 +29,8 @@ type GxLogger struct {
 	lock                sync.Mutex
 	level               int
 	l		   Logger
+	// prefix is written before every message, see WithRequestID
+	prefix              string
 }
 // Init file logger with json config.
 // jsonConfig like:
@@ -59,7 +61,7 @@ func (bl *GxLogger) writeToLoggers(msg string) {
 }
 
 func (bl *GxLogger) writeMsg(msg string) {
-	bl.writeToLoggers(msg)
+	bl.writeToLoggers(bl.prefix + msg)
 }
 
 func (bl *GxLogger) Flush() {
@@ -0,0 +1,146 @@
+package client
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// defaultTrustedProxies are the networks of the proxies trusted by
+// DefaultResolver: loopback addresses only, a proxy running on the same
+// host. Private networks are not trusted, any of their hosts could spoof
+// its address; list the proxies with NewIPResolver instead.
+var defaultTrustedProxies = []string{"127.0.0.0/8", "::1/128"}
+
+// DefaultResolver is the resolver used by GetClientIp.
+var DefaultResolver, _ = NewIPResolver(defaultTrustedProxies...)
+
+// IPResolver resolves the address of the client of a request, trusting the
+// forwarding headers only when they were set by one of its trusted proxies.
+type IPResolver struct {
+	trusted []*net.IPNet
+}
+
+// NewIPResolver returns a resolver trusting the proxies whose addresses are
+// in one of the given CIDR networks or are one of the given IPs.
+func NewIPResolver(trustedProxies ...string) (*IPResolver, error) {
+	r := &IPResolver{}
+	for _, p := range trustedProxies {
+		if !strings.Contains(p, "/") {
+			ip := net.ParseIP(p)
+			if ip == nil {
+				return nil, errors.New("client: invalid trusted proxy " + p)
+			}
+			bits := 8 * net.IPv6len
+			if ip.To4() != nil {
+				ip, bits = ip.To4(), 8*net.IPv4len
+			}
+			r.trusted = append(r.trusted, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, n, err := net.ParseCIDR(p)
+		if err != nil {
+			return nil, errors.New("client: invalid trusted proxy " + p)
+		}
+		r.trusted = append(r.trusted, n)
+	}
+	return r, nil
+}
+
+// Trusted reports whether ip is the address of a trusted proxy.
+func (r *IPResolver) Trusted(ip net.IP) bool {
+	for _, n := range r.trusted {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// ClientIP returns the address of the client of req. When the peer is a
+// trusted proxy, the chain of the Forwarded (RFC 7239) or X-Forwarded-For
+// header is walked from right to left, skipping trusted proxies, and the
+// first untrusted address is the client. Without these headers the
+// X-Real-IP header set by the proxy is used.
+func (r *IPResolver) ClientIP(req *http.Request) string {
+	remote := parseIP(req.RemoteAddr)
+	if remote == nil {
+		return stripPort(req.RemoteAddr)
+	}
+	if !r.Trusted(remote) {
+		return remote.String()
+	}
+
+	chain := forwardedFor(req.Header["Forwarded"])
+	if len(chain) == 0 {
+		chain = splitList(req.Header["X-Forwarded-For"])
+	}
+	if len(chain) == 0 {
+		if ip := parseIP(req.Header.Get("X-Real-IP")); ip != nil {
+			return ip.String()
+		}
+		return remote.String()
+	}
+
+	client := remote
+	for i := len(chain) - 1; i >= 0; i-- {
+		ip := parseIP(chain[i])
+		if ip == nil {
+			// an obfuscated or unknown hop, the last known one is the client
+			break
+		}
+		client = ip
+		if !r.Trusted(ip) {
+			break
+		}
+	}
+	return client.String()
+}
+
+// forwardedFor returns the "for" parameters of the Forwarded headers, from
+// the first hop to the last one.
+func forwardedFor(headers []string) []string {
+	var chain []string
+	for _, element := range splitList(headers) {
+		for _, pair := range strings.Split(element, ";") {
+			pair = strings.TrimSpace(pair)
+			if len(pair) > 4 && strings.EqualFold(pair[:4], "for=") {
+				chain = append(chain, strings.Trim(pair[4:], `"`))
+			}
+		}
+	}
+	return chain
+}
+
+// splitList splits the comma separated values of a header.
+func splitList(headers []string) []string {
+	var values []string
+	for _, h := range headers {
+		for _, v := range strings.Split(h, ",") {
+			if v = strings.TrimSpace(v); v != "" {
+				values = append(values, v)
+			}
+		}
+	}
+	return values
+}
+
+// parseIP parses an address with an optional port, the IPv6 ones being
+// bracketed then, like "[2001:db8::1]:8080".
+func parseIP(addr string) net.IP {
+	addr = strings.TrimSpace(addr)
+	if ip := net.ParseIP(addr); ip != nil {
+		return ip
+	}
+	return net.ParseIP(stripPort(addr))
+}
+
+// stripPort removes the port of an address and the brackets of an IPv6
+// address.
+func stripPort(addr string) string {
+	if host, _, err := net.SplitHostPort(addr); err == nil {
+		return host
+	}
+	return strings.TrimSuffix(strings.TrimPrefix(addr, "["), "]")
+}
@@ -0,0 +1,74 @@
+package client
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+func TestClientIP(t *testing.T) {
+	r, err := NewIPResolver("10.0.0.0/8", "2001:db8::/32", "192.0.2.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		remote  string
+		headers map[string]string
+		want    string
+	}{
+		// untrusted peers cannot spoof their address
+		{"203.0.113.9:1234", map[string]string{"X-Forwarded-For": "1.1.1.1"}, "203.0.113.9"},
+		{"[2001:db9::1]:443", map[string]string{"X-Real-IP": "1.1.1.1"}, "2001:db9::1"},
+		// the chain is walked right to left, skipping trusted proxies
+		{"10.0.0.1:1234", map[string]string{"X-Forwarded-For": "6.6.6.6, 198.51.100.7, 10.0.0.2"}, "198.51.100.7"},
+		{"192.0.2.1:1234", map[string]string{"X-Forwarded-For": "10.1.1.1, 10.0.0.2"}, "10.1.1.1"},
+		{"10.0.0.1:1234", map[string]string{"X-Forwarded-For": "unknown, 10.0.0.2"}, "10.0.0.2"},
+		{"10.0.0.1:1234", map[string]string{"X-Real-IP": "198.51.100.7"}, "198.51.100.7"},
+		{"10.0.0.1:1234", map[string]string{"X-Real-IP": "garbage"}, "10.0.0.1"},
+		// IPv6 addresses, bracketed with a port
+		{"[2001:db8::5]:8080", map[string]string{"X-Forwarded-For": "2001:db9::7"}, "2001:db9::7"},
+		{"[2001:db8::5]:8080", map[string]string{"X-Forwarded-For": "[2001:db9::7]:5555"}, "2001:db9::7"},
+		// RFC 7239 takes precedence over X-Forwarded-For
+		{"10.0.0.1:1234", map[string]string{
+			"Forwarded":       `for=192.0.2.60;proto=http, for="[2001:db9::1]:4711";by=10.0.0.1`,
+			"X-Forwarded-For": "6.6.6.6",
+		}, "2001:db9::1"},
+		{"10.0.0.1:1234", map[string]string{"Forwarded": "for=198.51.100.3, for=10.0.0.3"}, "198.51.100.3"},
+		{"10.0.0.1:1234", map[string]string{"Forwarded": "for=_hidden, for=10.0.0.3"}, "10.0.0.3"},
+		{"bad-address", nil, "bad-address"},
+	}
+	for _, test := range tests {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.RemoteAddr = test.remote
+		for k, v := range test.headers {
+			req.Header.Set(k, v)
+		}
+		if got := r.ClientIP(req); got != test.want {
+			t.Errorf("%s %v: got %s, want %s", test.remote, test.headers, got, test.want)
+		}
+	}
+}
+
+func TestNewIPResolver(t *testing.T) {
+	for _, p := range []string{"10.0.0.0/33", "not-an-ip", ""} {
+		if _, err := NewIPResolver(p); err == nil {
+			t.Errorf("%q: expected an error", p)
+		}
+	}
+}
+
+func TestGetClientIp(t *testing.T) {
+	req := httptest.NewRequest("GET", "/", nil)
+	req.RemoteAddr = "127.0.0.1:5000"
+	req.Header.Set("X-Forwarded-For", "198.51.100.7")
+	if got := GetClientIp(req); got != "198.51.100.7" {
+		t.Errorf("behind a local proxy: got %s", got)
+	}
+	req.RemoteAddr = "203.0.113.9:5000"
+	if got := GetClientIp(req); got != "203.0.113.9" {
+		t.Errorf("from a public address: got %s", got)
+	}
+	req.RemoteAddr = "10.0.0.8:5000"
+	if got := GetClientIp(req); got != "10.0.0.8" {
+		t.Errorf("from a private address: got %s", got)
+	}
+}
@@ -0,0 +1,209 @@
+package server
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Access log formats of ServerConfig.AccessLogFormat.
+const (
+	AccessLogDefault  = ""
+	AccessLogCommon   = "common"
+	AccessLogCombined = "combined"
+	AccessLogJSON     = "json"
+)
+
+const (
+	clfTimeFormat     = "02/Jan/2006:15:04:05 -0700"
+	maxLoggedParamLen = 1000
+	redacted          = "[REDACTED]"
+)
+
+// redactedParams are always masked in the access log.
+var redactedParams = []string{"password", "passwd", "pwd", "secret", "token", "access_token", "api_key", "apikey"}
+
+// AccessLogEntry describes a served request. Path is escaped, as in the
+// request line.
+type AccessLogEntry struct {
+	Time      time.Time         `json:"time"`
+	Client    string            `json:"client"`
+	Method    string            `json:"method"`
+	Path      string            `json:"path"`
+	Proto     string            `json:"proto"`
+	Status    int               `json:"status"`
+	Bytes     int64             `json:"bytes"`
+	Latency   time.Duration     `json:"-"`
+	UserAgent string            `json:"user_agent,omitempty"`
+	Referer   string            `json:"referer,omitempty"`
+	RequestID string            `json:"request_id,omitempty"`
+	Params    map[string]string `json:"params,omitempty"`
+}
+
+// logWriter records the status and the number of bytes of the response,
+// as sent on the wire.
+type logWriter struct {
+	http.ResponseWriter
+	status int
+	bytes  int64
+}
+
+func (w *logWriter) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *logWriter) Write(p []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	n, err := w.ResponseWriter.Write(p)
+	w.bytes += int64(n)
+	return n, err
+}
+
+func (w *logWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (w *logWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	h, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("http.Hijacker is not supported")
+	}
+	if w.status == 0 {
+		w.status = http.StatusSwitchingProtocols
+	}
+	return h.Hijack()
+}
+
+// logRequest writes the access log entry of a request in the configured
+// format.
+func (s *Server) logRequest(ctx *Context, sTime time.Time, w *logWriter) {
+	req := ctx.Request
+	entry := &AccessLogEntry{
+		Time:      sTime,
+		Client:    ctx.ClientIP(),
+		Method:    req.Method,
+		Path:      req.URL.EscapedPath(),
+		Proto:     req.Proto,
+		Status:    w.status,
+		Bytes:     w.bytes,
+		Latency:   time.Now().Sub(sTime),
+		UserAgent: req.UserAgent(),
+		Referer:   req.Referer(),
+		RequestID: ctx.requestID,
+		Params:    s.loggedParams(ctx.Params),
+	}
+	if entry.Status == 0 {
+		entry.Status = http.StatusOK
+	}
+
+	line := entry.Format(s.Config.AccessLogFormat)
+	if s.AccessLog != nil {
+		io.WriteString(s.AccessLog, line+"\n")
+		return
+	}
+	s.Logger.Print(line)
+}
+
+// loggedParams copies params, masking the redacted ones and truncating
+// the long values so the log stays small.
+func (s *Server) loggedParams(params map[string]string) map[string]string {
+	if len(params) == 0 {
+		return nil
+	}
+	logged := make(map[string]string, len(params))
+	for key, param := range params {
+		switch {
+		case s.redacts(key):
+			logged[key] = redacted
+		case len(param) > maxLoggedParamLen:
+			logged[key] = "len longger than 1000"
+		default:
+			logged[key] = param
+		}
+	}
+	return logged
+}
+
+func (s *Server) redacts(key string) bool {
+	for _, name := range redactedParams {
+		if strings.EqualFold(key, name) {
+			return true
+		}
+	}
+	for _, name := range s.Config.AccessLogRedact {
+		if strings.EqualFold(key, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// Format renders the entry as a single line in format, one of the
+// AccessLog constants.
+func (e *AccessLogEntry) Format(format string) string {
+	switch format {
+	case AccessLogCommon:
+		return e.common()
+	case AccessLogCombined:
+		return e.common() + " " + strconv.Quote(e.Referer) + " " + strconv.Quote(e.UserAgent)
+	case AccessLogJSON:
+		return e.json()
+	}
+
+	var logEntry bytes.Buffer
+	fmt.Fprintf(&logEntry, "%s - %s %s - %d %d - %v", e.Client, e.Method, logEscape(e.Path), e.Status, e.Bytes, e.Latency)
+	if e.RequestID != "" {
+		fmt.Fprintf(&logEntry, " - %s", e.RequestID)
+	}
+	if len(e.Params) > 0 {
+		params := make(map[string]string, len(e.Params))
+		for k, v := range e.Params {
+			params[logEscape(k)] = logEscape(v)
+		}
+		fmt.Fprintf(&logEntry, " - Params: %v", params)
+	}
+	return logEntry.String()
+}
+
+// logEscape escapes the quotes, backslashes and control characters of s,
+// so a client cannot break or forge a log line.
+func logEscape(s string) string {
+	q := strconv.Quote(s)
+	return q[1 : len(q)-1]
+}
+
+func (e *AccessLogEntry) common() string {
+	size := "-"
+	if e.Bytes > 0 {
+		size = strconv.FormatInt(e.Bytes, 10)
+	}
+	return fmt.Sprintf("%s - - [%s] \"%s %s %s\" %d %s",
+		e.Client, e.Time.Format(clfTimeFormat), e.Method, logEscape(e.Path), e.Proto, e.Status, size)
+}
+
+func (e *AccessLogEntry) json() string {
+	v := struct {
+		*AccessLogEntry
+		LatencyMs float64 `json:"latency_ms"`
+	}{e, float64(e.Latency) / float64(time.Millisecond)}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return e.common()
+	}
+	return string(b)
+}
@@ -0,0 +1,96 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestAccessLogFormats(t *testing.T) {
+	var buf bytes.Buffer
+	s := newTestServer(t)
+	s.AccessLog = &buf
+	s.Config.AccessLogRedact = []string{"card"}
+	s.Get("/hello", func(ctx *Context) string { return "hello" })
+
+	send := func(format string) string {
+		buf.Reset()
+		s.Config.AccessLogFormat = format
+		req := httptest.NewRequest("GET", "/hello?name=bob&password=hunter2&card=4242", nil)
+		req.Header.Set("User-Agent", "test-agent")
+		req.Header.Set("Referer", "http://example.com/")
+		req.Header.Set("X-Request-ID", "req-1")
+		s.ServeHTTP(httptest.NewRecorder(), req)
+		return buf.String()
+	}
+
+	line := send(AccessLogDefault)
+	for _, want := range []string{"GET /hello - 200 5", "req-1", "name:bob", "password:[REDACTED]", "card:[REDACTED]"} {
+		if !strings.Contains(line, want) {
+			t.Errorf("default line %q misses %q", line, want)
+		}
+	}
+	if strings.Contains(line, "hunter2") || strings.Contains(line, "4242") {
+		t.Errorf("default line %q leaks a redacted param", line)
+	}
+
+	line = send(AccessLogCommon)
+	if !strings.Contains(line, `] "GET /hello HTTP/1.1" 200 5`) || strings.Contains(line, "test-agent") {
+		t.Errorf("unexpected common line %q", line)
+	}
+
+	line = send(AccessLogCombined)
+	if !strings.HasSuffix(line, `200 5 "http://example.com/" "test-agent"`+"\n") {
+		t.Errorf("unexpected combined line %q", line)
+	}
+
+	var entry map[string]interface{}
+	if err := json.Unmarshal([]byte(send(AccessLogJSON)), &entry); err != nil {
+		t.Fatal(err)
+	}
+	if entry["status"] != 200.0 || entry["bytes"] != 5.0 || entry["request_id"] != "req-1" || entry["user_agent"] != "test-agent" {
+		t.Errorf("unexpected json entry %v", entry)
+	}
+	if _, ok := entry["latency_ms"]; !ok {
+		t.Errorf("json entry %v has no latency", entry)
+	}
+	if params := entry["params"].(map[string]interface{}); params["password"] != redacted {
+		t.Errorf("json params %v are not redacted", params)
+	}
+}
+
+func TestAccessLogStatus(t *testing.T) {
+	var buf bytes.Buffer
+	s := newTestServer(t)
+	s.AccessLog = &buf
+	s.Config.AccessLogFormat = AccessLogCommon
+	doRequest(s, "GET", "/missing", "")
+	if !strings.Contains(buf.String(), `"GET /missing HTTP/1.1" 404 `) {
+		t.Errorf("unexpected line %q", buf.String())
+	}
+}
+
+func TestAccessLogEscaping(t *testing.T) {
+	var buf bytes.Buffer
+	s := newTestServer(t)
+	s.AccessLog = &buf
+
+	for _, format := range []string{AccessLogDefault, AccessLogCommon, AccessLogCombined} {
+		buf.Reset()
+		s.Config.AccessLogFormat = format
+		req := httptest.NewRequest("GET", "/a%0Ab%22?q=x%0Ay%22z%5C", nil)
+		s.ServeHTTP(httptest.NewRecorder(), req)
+		line := strings.TrimSuffix(buf.String(), "\n")
+		if strings.ContainsAny(line, "\n\r") || !strings.Contains(line, "/a%0Ab%22") {
+			t.Errorf("%q: unexpected line %q", format, line)
+		}
+		if format == AccessLogDefault && !strings.Contains(line, `q:x\ny\"z\\`) {
+			t.Errorf("default line %q does not escape the params", line)
+		}
+		if format == AccessLogCommon && strings.Count(line, `"`) != 2 {
+			t.Errorf("common line %q has extra quotes", line)
+		}
+	}
+}
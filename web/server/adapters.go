@@ -0,0 +1,110 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"reflect"
+	"runtime/debug"
+)
+
+// compileHandler turns a route handler into a HandlerFunc once, when the
+// route is added. The common signatures get a typed adapter; the others
+// are called through reflection, which allocates on every request.
+func compileHandler(handler interface{}) HandlerFunc {
+	switch h := handler.(type) {
+	case HandlerFunc:
+		return h
+	case func(*Context):
+		return h
+	case func():
+		return func(ctx *Context) { h() }
+	case func(*Context) string:
+		return func(ctx *Context) { writeBody(ctx, http.StatusOK, []byte(h(ctx))) }
+	case func() string:
+		return func(ctx *Context) { writeBody(ctx, http.StatusOK, []byte(h())) }
+	case func(*Context) []byte:
+		return func(ctx *Context) { writeBody(ctx, http.StatusOK, h(ctx)) }
+	case func(*Context) error:
+		return func(ctx *Context) {
+			if err := h(ctx); err != nil {
+				ctx.HandleError(err)
+			}
+		}
+	case func(*Context) (string, error):
+		return func(ctx *Context) {
+			body, err := h(ctx)
+			if err != nil {
+				ctx.HandleError(err)
+				return
+			}
+			writeBody(ctx, http.StatusOK, []byte(body))
+		}
+	case func(*Context) interface{}:
+		return func(ctx *Context) { writeValue(ctx, http.StatusOK, h(ctx)) }
+	case func(*Context) (interface{}, error):
+		return func(ctx *Context) {
+			v, err := h(ctx)
+			if err != nil {
+				ctx.HandleError(err)
+				return
+			}
+			writeValue(ctx, http.StatusOK, v)
+		}
+	case func(*Context) (int, interface{}):
+		return func(ctx *Context) {
+			status, v := h(ctx)
+			writeValue(ctx, status, v)
+		}
+	case http.Handler:
+		return func(ctx *Context) { h.ServeHTTP(ctx.ResponseWriter, ctx.Request) }
+	case func(http.ResponseWriter, *http.Request):
+		return func(ctx *Context) { h(ctx.ResponseWriter, ctx.Request) }
+	case reflect.Value:
+		return reflectHandler(h)
+	}
+	return reflectHandler(reflect.ValueOf(handler))
+}
+
+// reflectHandler calls fn through reflection, passing the Context when it
+// is its first argument.
+func reflectHandler(fn reflect.Value) HandlerFunc {
+	withContext := requiresContext(fn.Type())
+	return func(ctx *Context) {
+		var args []reflect.Value
+		if withContext {
+			args = []reflect.Value{reflect.ValueOf(ctx)}
+		}
+		ret := fn.Call(args)
+		if len(ret) == 0 {
+			return
+		}
+		// a non nil error returned last aborts the request
+		if last := ret[len(ret)-1]; last.Type().Implements(errorType) {
+			if !isNilValue(last) {
+				ctx.HandleError(last.Interface().(error))
+				return
+			}
+			ret = ret[:len(ret)-1]
+			if len(ret) == 0 {
+				return
+			}
+		}
+		writeResult(ctx, ret)
+	}
+}
+
+// recoverPanic answers a panic of the request with a 500 when
+// Config.RecoverPanic is set, logging it as a single entry. It must be
+// deferred by routeHandler, around the middlewares, filters and handler.
+func (s *Server) recoverPanic(ctx *Context) {
+	e := recover()
+	if e == nil {
+		return
+	}
+	if !s.Config.RecoverPanic || e == http.ErrAbortHandler {
+		// go back to panic
+		panic(e)
+	}
+	ctx.panicReport(e, debug.Stack())
+	s.handleError(ctx, HTTPError{Code: 500, Message: "Server Error", Err: fmt.Errorf("panic: %v", e)})
+}
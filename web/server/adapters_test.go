@@ -0,0 +1,94 @@
+package server
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestCompileHandler(t *testing.T) {
+	s := newTestServer(t)
+	s.Get("/ctx", func(ctx *Context) { ctx.WriteString("ctx") })
+	s.Get("/handlerfunc", HandlerFunc(func(ctx *Context) { ctx.WriteString("hf") }))
+	s.Get("/string", func(ctx *Context) string { return "s:" + ctx.Params["q"] })
+	s.Get("/bytes", func(ctx *Context) []byte { return []byte("b") })
+	s.Get("/error", func(ctx *Context) error { return HTTPError{Code: 409} })
+	s.Get("/string-error", func(ctx *Context) (string, error) { return "", errors.New("x") })
+	s.Get("/value", func(ctx *Context) interface{} { return map[string]int{"a": 1} })
+	s.Get("/value-error", func(ctx *Context) (interface{}, error) { return []int{1, 2}, nil })
+	s.Get("/status", func(ctx *Context) (int, interface{}) { return 201, "made" })
+	s.Get("/std", func(w http.ResponseWriter, r *http.Request) { w.Write([]byte("std")) })
+	s.Get("/exotic", func(ctx *Context) (int, []string, error) { return 203, []string{"x"}, nil })
+	s.Get("/panic", func(ctx *Context) { panic("typed panic") })
+
+	tests := []struct {
+		path string
+		code int
+		body string
+	}{
+		{"/ctx", 200, "ctx"},
+		{"/handlerfunc", 200, "hf"},
+		{"/string?q=1", 200, "s:1"},
+		{"/bytes", 200, "b"},
+		{"/error", 409, "Conflict"},
+		{"/string-error", 500, "Internal Server Error"},
+		{"/value", 200, `{"a":1}`},
+		{"/value-error", 200, `[1,2]`},
+		{"/status", 201, "made"},
+		{"/std", 200, "std"},
+		{"/exotic", 203, `["x"]`},
+		{"/panic", 500, "Server Error"},
+	}
+	for _, test := range tests {
+		w := doRequest(s, "GET", test.path, "")
+		if w.Code != test.code || w.Body.String() != test.body {
+			t.Errorf("%s: got %d %q, want %d %q", test.path, w.Code, w.Body.String(), test.code, test.body)
+		}
+	}
+}
+
+func benchmarkAdapter(b *testing.B, h HandlerFunc) {
+	s := newTestServer(nil)
+	ctx := &Context{Request: httptest.NewRequest("GET", "/", nil), Params: map[string]string{}, Server: s}
+	w := httptest.NewRecorder()
+	ctx.ResponseWriter = w
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		w.Body.Reset()
+		h(ctx)
+	}
+}
+
+func hello(ctx *Context) string { return "hello" }
+
+func BenchmarkTypedHandler(b *testing.B) {
+	benchmarkAdapter(b, compileHandler(hello))
+}
+
+func BenchmarkReflectHandler(b *testing.B) {
+	benchmarkAdapter(b, reflectHandler(reflect.ValueOf(hello)))
+}
+
+func BenchmarkServeTypedHandler(b *testing.B) {
+	s := newTestServer(nil)
+	s.Get("/hello", hello)
+	benchmarkServe(b, s)
+}
+
+func BenchmarkServeReflectHandler(b *testing.B) {
+	s := newTestServer(nil)
+	s.Get("/hello", reflect.ValueOf(hello))
+	benchmarkServe(b, s)
+}
+
+func benchmarkServe(b *testing.B, s *Server) {
+	req := httptest.NewRequest("GET", "/hello", nil)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		s.ServeHTTP(httptest.NewRecorder(), req)
+	}
+}
@@ -0,0 +1,168 @@
+package server
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// Bind decodes the request into v, which must be a pointer to a struct.
+// JSON and XML bodies are decoded according to the Content-Type header;
+// query strings and form bodies are mapped to the struct fields by their
+// `form` tag, or by field name when there is no tag. A tag of "-" skips
+// the field. Bodies over Config.MaxBodySize give a 413 HTTPError.
+func (ctx *Context) Bind(v interface{}) error {
+	ctype, _, _ := mime.ParseMediaType(ctx.Request.Header.Get("Content-Type"))
+	switch {
+	case ctype == "application/json" || strings.HasSuffix(ctype, "+json"):
+		if ctx.Request.Body == nil {
+			return errors.New("empty request body")
+		}
+		return ctx.bodyError(json.NewDecoder(ctx.Request.Body).Decode(v))
+	case ctype == "application/xml" || ctype == "text/xml" || strings.HasSuffix(ctype, "+xml"):
+		if ctx.Request.Body == nil {
+			return errors.New("empty request body")
+		}
+		return ctx.bodyError(xml.NewDecoder(ctx.Request.Body).Decode(v))
+	}
+	ctx.parseForm()
+	if ctx.tooLarge {
+		return errBodyTooLarge
+	}
+	return mapForm(v, ctx.Request.Form)
+}
+
+var errBodyTooLarge = HTTPError{Code: http.StatusRequestEntityTooLarge, Message: "Request Entity Too Large"}
+
+// bodyError turns the error of reading a body over Config.MaxBodySize,
+// sent without a Content-Length, into a 413.
+func (ctx *Context) bodyError(err error) error {
+	if err != nil && ctx.body != nil && ctx.body.tooLarge {
+		return errBodyTooLarge
+	}
+	return err
+}
+
+// mapForm sets the fields of the struct pointed to by v from form values.
+func mapForm(v interface{}, form map[string][]string) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() || rv.Elem().Kind() != reflect.Struct {
+		return errors.New("bind: expected a pointer to a struct")
+	}
+	return mapStruct(rv.Elem(), form)
+}
+
+func mapStruct(sv reflect.Value, form map[string][]string) error {
+	st := sv.Type()
+	for i := 0; i < st.NumField(); i++ {
+		field := st.Field(i)
+		fv := sv.Field(i)
+		tag := field.Tag.Get("form")
+		if tag == "-" {
+			continue
+		}
+		if field.Anonymous && fv.Kind() == reflect.Struct && tag == "" {
+			if err := mapStruct(fv, form); err != nil {
+				return err
+			}
+			continue
+		}
+		if field.PkgPath != "" {
+			// unexported field
+			continue
+		}
+		name := tag
+		if name == "" {
+			name = field.Name
+		}
+		values, ok := form[name]
+		if !ok || len(values) == 0 {
+			continue
+		}
+		if err := setField(fv, values); err != nil {
+			return fmt.Errorf("bind: field %s: %v", name, err)
+		}
+	}
+	return nil
+}
+
+// setField sets fv from values, all of them for slices and the first one
+// otherwise.
+func setField(fv reflect.Value, values []string) error {
+	switch fv.Kind() {
+	case reflect.Slice:
+		if fv.Type().Elem().Kind() == reflect.Uint8 {
+			fv.SetBytes([]byte(values[0]))
+			return nil
+		}
+		slice := reflect.MakeSlice(fv.Type(), len(values), len(values))
+		for i, value := range values {
+			if err := setValue(slice.Index(i), value); err != nil {
+				return err
+			}
+		}
+		fv.Set(slice)
+		return nil
+	case reflect.Ptr:
+		ptr := reflect.New(fv.Type().Elem())
+		if err := setField(ptr.Elem(), values); err != nil {
+			return err
+		}
+		fv.Set(ptr)
+		return nil
+	}
+	return setValue(fv, values[0])
+}
+
+func setValue(fv reflect.Value, value string) error {
+	switch fv.Kind() {
+	case reflect.String:
+		fv.SetString(value)
+	case reflect.Bool:
+		if value == "" || value == "on" {
+			fv.SetBool(value == "on")
+			return nil
+		}
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return err
+		}
+		fv.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if value == "" {
+			value = "0"
+		}
+		n, err := strconv.ParseInt(value, 10, fv.Type().Bits())
+		if err != nil {
+			return err
+		}
+		fv.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if value == "" {
+			value = "0"
+		}
+		n, err := strconv.ParseUint(value, 10, fv.Type().Bits())
+		if err != nil {
+			return err
+		}
+		fv.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		if value == "" {
+			value = "0"
+		}
+		f, err := strconv.ParseFloat(value, fv.Type().Bits())
+		if err != nil {
+			return err
+		}
+		fv.SetFloat(f)
+	default:
+		return errors.New("unsupported type " + fv.Type().String())
+	}
+	return nil
+}
@@ -0,0 +1,96 @@
+package server
+
+import (
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+type bindUser struct {
+	Name  string   `form:"name" json:"name" xml:"name"`
+	Age   int      `form:"age" json:"age" xml:"age"`
+	Tags  []string `form:"tag" json:"tags" xml:"tag"`
+	Admin bool     `form:"admin" json:"admin" xml:"admin"`
+	Skip  string   `form:"-"`
+}
+
+func TestBind(t *testing.T) {
+	s := newTestServer(t)
+	var got bindUser
+	s.Post("/bind", func(ctx *Context) string {
+		got = bindUser{}
+		if err := ctx.Bind(&got); err != nil {
+			return err.Error()
+		}
+		return "ok"
+	})
+
+	tests := []struct {
+		ctype string
+		body  string
+	}{
+		{"application/x-www-form-urlencoded", "name=bob&age=42&tag=a&tag=b&admin=on&Skip=x"},
+		{"application/json; charset=utf-8", `{"name":"bob","age":42,"tags":["a","b"],"admin":true}`},
+		{"application/xml", `<bindUser><name>bob</name><age>42</age><tag>a</tag><tag>b</tag><admin>true</admin></bindUser>`},
+	}
+	for _, test := range tests {
+		req := httptest.NewRequest("POST", "/bind", strings.NewReader(test.body))
+		req.Header.Set("Content-Type", test.ctype)
+		w := httptest.NewRecorder()
+		s.ServeHTTP(w, req)
+		if w.Body.String() != "ok" {
+			t.Errorf("%s: %s", test.ctype, w.Body.String())
+			continue
+		}
+		if got.Name != "bob" || got.Age != 42 || strings.Join(got.Tags, ",") != "a,b" || !got.Admin || got.Skip != "" {
+			t.Errorf("%s: unexpected binding %+v", test.ctype, got)
+		}
+	}
+
+	if w := doRequest(s, "POST", "/bind", "age=old"); !strings.Contains(w.Body.String(), "field age") {
+		t.Errorf("expected a field error got %q", w.Body.String())
+	}
+}
+
+func TestBindTooLarge(t *testing.T) {
+	s := newTestServer(t)
+	s.Config.MaxBodySize = 64
+	s.Post("/bind", func(ctx *Context) error {
+		var u bindUser
+		return ctx.Bind(&u)
+	})
+
+	for _, ctype := range []string{"application/json", "application/xml"} {
+		body := `{"name":"` + strings.Repeat("x", 128) + `"}`
+		if ctype == "application/xml" {
+			body = "<bindUser><name>" + strings.Repeat("x", 128) + "</name></bindUser>"
+		}
+		// a reader of unknown length is sent chunked, without Content-Length
+		req := httptest.NewRequest("POST", "/bind", io.MultiReader(strings.NewReader(body)))
+		req.Header.Set("Content-Type", ctype)
+		w := httptest.NewRecorder()
+		s.ServeHTTP(w, req)
+		if w.Code != 413 {
+			t.Errorf("%s: expected 413 got %d %q", ctype, w.Code, w.Body.String())
+		}
+	}
+}
+
+func TestJSONP(t *testing.T) {
+	s := newTestServer(t)
+	s.Get("/jsonp", func(ctx *Context) {
+		if err := ctx.JSONP(200, "", map[string]int{"a": 1}); err != nil {
+			ctx.Abort(400, err.Error())
+		}
+	})
+	if w := doRequest(s, "GET", "/jsonp?callback=cb", ""); w.Body.String() != `/**/cb({"a":1});` {
+		t.Errorf("unexpected jsonp body %q", w.Body.String())
+	}
+	if w := doRequest(s, "GET", "/jsonp", ""); w.Body.String() != `{"a":1}` {
+		t.Errorf("unexpected json body %q", w.Body.String())
+	}
+	if w := doRequest(s, "GET", "/jsonp?callback=alert(1)", ""); w.Code != 400 {
+		t.Errorf("expected 400 for invalid callback got %d", w.Code)
+	}
+}
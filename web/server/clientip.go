@@ -0,0 +1,29 @@
+package server
+
+import (
+	"github.com/widaT/golib/web/client"
+)
+
+// ClientIP returns the address of the client, trusting the forwarding
+// headers only when they were set by one of Config.TrustedProxies.
+func (ctx *Context) ClientIP() string {
+	return ctx.Server.ipResolver().ClientIP(ctx.Request)
+}
+
+// ipResolver builds the resolver of Config.TrustedProxies on first use.
+func (s *Server) ipResolver() *client.IPResolver {
+	s.resolverOnce.Do(func() {
+		s.resolver = client.DefaultResolver
+		if s.Config.TrustedProxies == nil {
+			return
+		}
+		r, err := client.NewIPResolver(s.Config.TrustedProxies...)
+		if err != nil {
+			// trust no proxy rather than more than configured
+			s.Logger.Error("TrustedProxies: %v", err)
+			r, _ = client.NewIPResolver()
+		}
+		s.resolver = r
+	})
+	return s.resolver
+}
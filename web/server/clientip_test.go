@@ -0,0 +1,33 @@
+package server
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+func TestContextClientIP(t *testing.T) {
+	tests := []struct {
+		trusted []string
+		remote  string
+		want    string
+	}{
+		{nil, "192.0.2.1:1234", "192.0.2.1"},
+		{nil, "10.0.0.8:1234", "10.0.0.8"},
+		{nil, "127.0.0.1:1234", "198.51.100.7"},
+		{[]string{"192.0.2.0/24"}, "192.0.2.1:1234", "198.51.100.7"},
+		{[]string{"not-a-network"}, "192.0.2.1:1234", "192.0.2.1"},
+	}
+	for _, test := range tests {
+		s := newTestServer(t)
+		s.Config.TrustedProxies = test.trusted
+		s.Get("/ip", func(ctx *Context) string { return ctx.ClientIP() })
+		req := httptest.NewRequest("GET", "/ip", nil)
+		req.RemoteAddr = test.remote
+		req.Header.Set("X-Forwarded-For", "198.51.100.7")
+		w := httptest.NewRecorder()
+		s.ServeHTTP(w, req)
+		if w.Body.String() != test.want {
+			t.Errorf("trusting %v from %s: got %s, want %s", test.trusted, test.remote, w.Body.String(), test.want)
+		}
+	}
+}
@@ -0,0 +1,280 @@
+package server
+
+import (
+	"bufio"
+	"compress/gzip"
+	"compress/zlib"
+	"errors"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// defaultCompressMinLength is the smallest body compressed when
+// Config.CompressMinLength is not set.
+const defaultCompressMinLength = 256
+
+// Compressor is a compressing writer which can be reset to be reused for
+// another response.
+type Compressor interface {
+	io.WriteCloser
+	Reset(w io.Writer)
+}
+
+type compressorFlusher interface {
+	Flush() error
+}
+
+type encoding struct {
+	name string
+	pool *sync.Pool
+}
+
+var (
+	encodingsLock sync.RWMutex
+	// encodings in order of preference when the client accepts several
+	// with the same quality
+	encodings = []*encoding{
+		newEncoding("gzip", func(w io.Writer) Compressor {
+			return gzip.NewWriter(w)
+		}),
+		// HTTP's deflate is the zlib format, not raw deflate
+		newEncoding("deflate", func(w io.Writer) Compressor {
+			zw, _ := zlib.NewWriterLevel(w, zlib.DefaultCompression)
+			return zw
+		}),
+	}
+)
+
+func newEncoding(name string, newFn func(w io.Writer) Compressor) *encoding {
+	return &encoding{name: name, pool: &sync.Pool{New: func() interface{} { return newFn(nil) }}}
+}
+
+// RegisterCompressor adds a content encoding, such as "br" backed by a
+// third party brotli package, preferred over the built-in gzip and deflate
+// ones. newFn must accept a nil writer, compressors being Reset before use.
+func RegisterCompressor(name string, newFn func(w io.Writer) Compressor) {
+	encodingsLock.Lock()
+	defer encodingsLock.Unlock()
+	encodings = append([]*encoding{newEncoding(name, newFn)}, encodings...)
+}
+
+// negotiateEncoding returns the supported encoding with the highest
+// quality in the Accept-Encoding header, or nil.
+func negotiateEncoding(header string) *encoding {
+	if header == "" {
+		return nil
+	}
+	qualities := map[string]float64{}
+	for _, part := range strings.Split(header, ",") {
+		name, q := part, 1.0
+		if i := strings.IndexByte(part, ';'); i >= 0 {
+			name = part[:i]
+			param := strings.TrimSpace(part[i+1:])
+			if strings.HasPrefix(param, "q=") {
+				if f, err := strconv.ParseFloat(param[2:], 64); err == nil {
+					q = f
+				}
+			}
+		}
+		qualities[strings.ToLower(strings.TrimSpace(name))] = q
+	}
+
+	encodingsLock.RLock()
+	defer encodingsLock.RUnlock()
+	var best *encoding
+	bestQ := 0.0
+	for _, enc := range encodings {
+		q, ok := qualities[enc.name]
+		if !ok {
+			q, ok = qualities["*"]
+		}
+		if ok && q > bestQ {
+			best, bestQ = enc, q
+		}
+	}
+	return best
+}
+
+// incompressibleTypes are content types already compressed.
+var incompressibleTypes = []string{
+	"image/", "video/", "audio/", "font/woff",
+	"application/zip", "application/gzip", "application/x-gzip",
+	"application/x-bzip2", "application/x-7z-compressed", "application/x-rar-compressed",
+	"application/pdf", "application/octet-stream", "application/wasm",
+	"text/event-stream",
+}
+
+func compressible(contentType string) bool {
+	ctype, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	if ctype == "image/svg+xml" {
+		return true
+	}
+	for _, t := range incompressibleTypes {
+		if strings.HasPrefix(ctype, t) {
+			return false
+		}
+	}
+	return true
+}
+
+// compressWriter compresses the response with the negotiated encoding.
+// The body is buffered until it reaches the minimum length, so the
+// decision to compress can account for its size and content type.
+type compressWriter struct {
+	http.ResponseWriter
+	enc       *encoding
+	minLength int
+
+	status  int
+	buf     []byte
+	decided bool
+	cw      Compressor
+}
+
+// newCompressWriter wraps w if the client accepts a supported encoding,
+// and returns nil otherwise.
+func newCompressWriter(w http.ResponseWriter, req *http.Request, config *ServerConfig) *compressWriter {
+	enc := negotiateEncoding(req.Header.Get("Accept-Encoding"))
+	if enc == nil {
+		return nil
+	}
+	minLength := config.CompressMinLength
+	if minLength <= 0 {
+		minLength = defaultCompressMinLength
+	}
+	return &compressWriter{ResponseWriter: w, enc: enc, minLength: minLength, status: http.StatusOK}
+}
+
+func (w *compressWriter) WriteHeader(status int) {
+	if w.decided {
+		return
+	}
+	w.status = status
+	if !bodyAllowed(status) {
+		w.decide(false)
+	}
+}
+
+func (w *compressWriter) Write(p []byte) (int, error) {
+	if !w.decided {
+		w.buf = append(w.buf, p...)
+		if len(w.buf) < w.minLength {
+			return len(p), nil
+		}
+		if err := w.decide(true); err != nil {
+			return 0, err
+		}
+		return len(p), nil
+	}
+	if w.cw != nil {
+		return w.cw.Write(p)
+	}
+	return w.ResponseWriter.Write(p)
+}
+
+// decide sends the headers, compressing the body if it is allowed, then
+// writes the buffered data.
+func (w *compressWriter) decide(allowed bool) error {
+	w.decided = true
+	h := w.Header()
+	if h.Get("Content-Type") == "" && len(w.buf) > 0 {
+		h.Set("Content-Type", http.DetectContentType(w.buf))
+	}
+	if allowed && bodyAllowed(w.status) && w.status != http.StatusPartialContent &&
+		h.Get("Content-Encoding") == "" && compressible(h.Get("Content-Type")) {
+		h.Set("Content-Encoding", w.enc.name)
+		h.Add("Vary", "Accept-Encoding")
+		h.Del("Content-Length")
+		w.cw = w.enc.pool.Get().(Compressor)
+		w.cw.Reset(w.ResponseWriter)
+	}
+	w.ResponseWriter.WriteHeader(w.status)
+	buf := w.buf
+	w.buf = nil
+	if len(buf) == 0 {
+		return nil
+	}
+	var err error
+	if w.cw != nil {
+		_, err = w.cw.Write(buf)
+	} else {
+		_, err = w.ResponseWriter.Write(buf)
+	}
+	return err
+}
+
+// Flush sends the pending data, compressing what is buffered even if it
+// is below the minimum length as the handler is streaming.
+func (w *compressWriter) Flush() {
+	if !w.decided {
+		w.decide(true)
+	}
+	if w.cw != nil {
+		if f, ok := w.cw.(compressorFlusher); ok {
+			f.Flush()
+		}
+	}
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Close writes the end of the response and returns the compressor to
+// its pool.
+func (w *compressWriter) Close() error {
+	if !w.decided {
+		w.decide(len(w.buf) >= w.minLength)
+	}
+	if w.cw == nil {
+		return nil
+	}
+	err := w.cw.Close()
+	w.cw.Reset(nil)
+	w.enc.pool.Put(w.cw)
+	w.cw = nil
+	return err
+}
+
+// Hijack lets WebSocket upgrades through the compression layer.
+func (w *compressWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	h, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("http.Hijacker is not supported")
+	}
+	w.decided = true
+	return h.Hijack()
+}
+
+// bodyAllowed reports whether a response with status may have a body.
+func bodyAllowed(status int) bool {
+	return !(status >= 100 && status <= 199) && status != http.StatusNoContent && status != http.StatusNotModified
+}
+
+// Compress is a middleware compressing the responses of the routes it
+// wraps with the best encoding accepted by the client. Bodies shorter
+// than Config.CompressMinLength and already compressed content types are
+// sent as is. Setting Config.GZIP compresses every response of the server.
+func Compress(next HandlerFunc) HandlerFunc {
+	return func(ctx *Context) {
+		cw := newCompressWriter(ctx.ResponseWriter, ctx.Request, ctx.Server.Config)
+		if cw == nil {
+			next(ctx)
+			return
+		}
+		w := ctx.ResponseWriter
+		ctx.ResponseWriter = cw
+		defer func() {
+			cw.Close()
+			ctx.ResponseWriter = w
+		}()
+		next(ctx)
+	}
+}
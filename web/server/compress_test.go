@@ -0,0 +1,95 @@
+package server
+
+import (
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"io/ioutil"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestNegotiateEncoding(t *testing.T) {
+	tests := map[string]string{
+		"":                           "",
+		"gzip":                       "gzip",
+		"deflate, gzip":              "gzip",
+		"gzip;q=0.5, deflate":        "deflate",
+		"br":                         "",
+		"*":                          "gzip",
+		"gzip;q=0, *;q=0.1":          "deflate",
+		"identity, deflate;q=0.9":    "deflate",
+		"GZIP;q=1.0, deflate;q=0.99": "gzip",
+	}
+	for header, expected := range tests {
+		name := ""
+		if enc := negotiateEncoding(header); enc != nil {
+			name = enc.name
+		}
+		if name != expected {
+			t.Errorf("%q: expected %q got %q", header, expected, name)
+		}
+	}
+}
+
+func TestCompression(t *testing.T) {
+	s := newTestServer(t)
+	s.Config.GZIP = true
+	long := strings.Repeat("hello world ", 100)
+	s.Get("/long", func() string { return long })
+	s.Get("/short", func() string { return "hi" })
+	s.Get("/write", func(ctx *Context) { ctx.WriteString(long) })
+	s.Get("/png", func(ctx *Context) {
+		ctx.ContentType("png")
+		ctx.WriteString(long)
+	})
+
+	tests := []struct {
+		path     string
+		accept   string
+		encoding string
+	}{
+		{"/long", "gzip", "gzip"},
+		{"/long", "deflate", "deflate"},
+		{"/long", "", ""},
+		{"/short", "gzip", ""},
+		{"/write", "gzip, deflate", "gzip"},
+		{"/png", "gzip", ""},
+	}
+	for _, test := range tests {
+		req := httptest.NewRequest("GET", test.path, nil)
+		req.Header.Set("Accept-Encoding", test.accept)
+		w := httptest.NewRecorder()
+		s.ServeHTTP(w, req)
+		if got := w.Header().Get("Content-Encoding"); got != test.encoding {
+			t.Errorf("%s %q: expected encoding %q got %q", test.path, test.accept, test.encoding, got)
+			continue
+		}
+		var r io.Reader = w.Body
+		switch test.encoding {
+		case "gzip":
+			gz, err := gzip.NewReader(w.Body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			r = gz
+		case "deflate":
+			zr, err := zlib.NewReader(w.Body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			r = zr
+		}
+		body, err := ioutil.ReadAll(r)
+		if err != nil {
+			t.Errorf("%s: %v", test.path, err)
+		}
+		if test.path != "/short" && string(body) != long {
+			t.Errorf("%s %q: unexpected body", test.path, test.accept)
+		}
+		if test.encoding != "" && w.Header().Get("Content-Length") != "" {
+			t.Errorf("%s: Content-Length must be dropped when compressing", test.path)
+		}
+	}
+}
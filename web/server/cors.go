@@ -0,0 +1,135 @@
+package server
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CORSConfig configures the Cross-Origin Resource Sharing headers of a
+// server, see Server.CORS.
+type CORSConfig struct {
+	// AllowOrigins are the origins allowed to send requests. An origin may
+	// contain a wildcard, like "https://*.example.com", and "*" allows any
+	// origin.
+	AllowOrigins []string
+	// AllowMethods restricts the methods allowed by preflight requests.
+	// Empty allows the methods the matched route serves.
+	AllowMethods []string
+	// AllowHeaders are the request headers allowed by preflight requests.
+	// Empty allows the headers requested by the client.
+	AllowHeaders []string
+	// ExposeHeaders are the response headers readable by the client.
+	ExposeHeaders []string
+	// AllowCredentials allows cookies and authorization headers. It cannot
+	// be combined with the "*" origin, which would let any site make
+	// authenticated requests.
+	AllowCredentials bool
+	// MaxAge is how long the client may cache a preflight response.
+	MaxAge time.Duration
+}
+
+// CORS enables Cross-Origin Resource Sharing for the routes of s. Preflight
+// requests are answered before the middlewares and filters run, so they
+// need no authentication, allowing the methods of the matched route only.
+// The other requests from allowed origins get the CORS headers, including
+// their error responses. It panics when AllowCredentials is set with the
+// "*" origin.
+func (s *Server) CORS(config CORSConfig) {
+	if config.AllowCredentials && config.allowsAnyOrigin() {
+		panic("cors: AllowCredentials cannot be used with the \"*\" origin, list the allowed origins")
+	}
+	s.cors = &config
+}
+
+// handleCORS decorates the response for an allowed origin and reports
+// whether the request was a preflight, which is then answered.
+func (c *CORSConfig) handleCORS(ctx *Context) bool {
+	req := ctx.Request
+	origin := req.Header.Get("Origin")
+	if origin == "" {
+		return false
+	}
+	h := ctx.Header()
+	h.Add("Vary", "Origin")
+
+	preflight := req.Method == "OPTIONS" && req.Header.Get("Access-Control-Request-Method") != ""
+	if preflight {
+		h.Add("Vary", "Access-Control-Request-Method")
+		h.Add("Vary", "Access-Control-Request-Headers")
+	}
+	if !matchOrigin(origin, c.AllowOrigins) {
+		return c.answerPreflight(ctx, preflight)
+	}
+
+	if !c.allowsAnyOrigin() {
+		h.Set("Access-Control-Allow-Origin", origin)
+	} else {
+		h.Set("Access-Control-Allow-Origin", "*")
+	}
+	if c.AllowCredentials {
+		h.Set("Access-Control-Allow-Credentials", "true")
+	}
+	if !preflight {
+		if len(c.ExposeHeaders) > 0 {
+			h.Set("Access-Control-Expose-Headers", strings.Join(c.ExposeHeaders, ", "))
+		}
+		return false
+	}
+
+	method := strings.ToUpper(req.Header.Get("Access-Control-Request-Method"))
+	if !c.allowsMethod(method) || ctx.routes == nil || ctx.routes.lookup(method) == nil {
+		// the browser fails the request for lack of allow headers
+		h.Del("Access-Control-Allow-Origin")
+		h.Del("Access-Control-Allow-Credentials")
+		return c.answerPreflight(ctx, preflight)
+	}
+	if len(c.AllowMethods) > 0 {
+		h.Set("Access-Control-Allow-Methods", strings.Join(c.AllowMethods, ", "))
+	} else {
+		h.Set("Access-Control-Allow-Methods", method)
+	}
+	if len(c.AllowHeaders) > 0 {
+		h.Set("Access-Control-Allow-Headers", strings.Join(c.AllowHeaders, ", "))
+	} else if headers := req.Header.Get("Access-Control-Request-Headers"); headers != "" {
+		h.Set("Access-Control-Allow-Headers", headers)
+	}
+	if c.MaxAge > 0 {
+		h.Set("Access-Control-Max-Age", strconv.Itoa(int(c.MaxAge/time.Second)))
+	}
+	return c.answerPreflight(ctx, preflight)
+}
+
+// answerPreflight answers a preflight request with an empty 204.
+func (c *CORSConfig) answerPreflight(ctx *Context, preflight bool) bool {
+	if !preflight {
+		return false
+	}
+	h := ctx.Header()
+	h.Del("Content-Type")
+	h.Set("Content-Length", "0")
+	ctx.WriteHeader(http.StatusNoContent)
+	return true
+}
+
+func (c *CORSConfig) allowsAnyOrigin() bool {
+	for _, o := range c.AllowOrigins {
+		if o == "*" {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *CORSConfig) allowsMethod(method string) bool {
+	if len(c.AllowMethods) == 0 {
+		return true
+	}
+	for _, m := range c.AllowMethods {
+		if strings.EqualFold(m, method) {
+			return true
+		}
+	}
+	return false
+}
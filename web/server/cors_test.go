@@ -0,0 +1,99 @@
+package server
+
+import (
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestCORS(t *testing.T) {
+	s := newTestServer(t)
+	s.CORS(CORSConfig{
+		AllowOrigins:     []string{"https://app.example.com", "https://*.example.org"},
+		ExposeHeaders:    []string{"X-Total"},
+		AllowCredentials: true,
+		MaxAge:           10 * time.Minute,
+	})
+	s.AddFilter("/api/.*", func(ctx *Context) bool {
+		ctx.Abort(401, "Unauthorized")
+		return false
+	})
+	s.Get("/api/items", func() string { return "items" })
+	s.Put("/api/items", func() string { return "updated" })
+
+	send := func(method, origin, requestMethod string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, "/api/items", nil)
+		req.Header.Set("Origin", origin)
+		if requestMethod != "" {
+			req.Header.Set("Access-Control-Request-Method", requestMethod)
+			req.Header.Set("Access-Control-Request-Headers", "Content-Type, X-Token")
+		}
+		w := httptest.NewRecorder()
+		s.ServeHTTP(w, req)
+		return w
+	}
+
+	// the preflight is answered before the authentication filter
+	w := send("OPTIONS", "https://app.example.com", "PUT")
+	h := w.Header()
+	if w.Code != 204 || h.Get("Access-Control-Allow-Origin") != "https://app.example.com" ||
+		h.Get("Access-Control-Allow-Methods") != "PUT" || h.Get("Access-Control-Allow-Headers") != "Content-Type, X-Token" ||
+		h.Get("Access-Control-Allow-Credentials") != "true" || h.Get("Access-Control-Max-Age") != "600" {
+		t.Errorf("preflight: got %d %v", w.Code, h)
+	}
+
+	w = send("OPTIONS", "https://api.example.org", "DELETE")
+	if w.Code != 204 || w.Header().Get("Access-Control-Allow-Origin") != "" || w.Header().Get("Access-Control-Allow-Methods") != "" {
+		t.Errorf("preflight of a method the route does not serve: got %d %v", w.Code, w.Header())
+	}
+
+	w = send("OPTIONS", "https://evil.com", "PUT")
+	if w.Header().Get("Access-Control-Allow-Origin") != "" {
+		t.Errorf("preflight from a denied origin: got %v", w.Header())
+	}
+
+	w = send("GET", "https://api.example.org", "")
+	h = w.Header()
+	if w.Code != 401 || h.Get("Access-Control-Allow-Origin") != "https://api.example.org" ||
+		h.Get("Access-Control-Expose-Headers") != "X-Total" || h.Get("Vary") != "Origin" {
+		t.Errorf("actual request: got %d %v", w.Code, h)
+	}
+
+	w = send("GET", "https://example.org", "")
+	if w.Header().Get("Access-Control-Allow-Origin") != "" {
+		t.Errorf("request from a denied origin: got %v", w.Header())
+	}
+}
+
+func TestCORSAnyOrigin(t *testing.T) {
+	s := newTestServer(t)
+	s.CORS(CORSConfig{AllowOrigins: []string{"*"}, AllowMethods: []string{"GET", "POST"}, AllowHeaders: []string{"Content-Type"}})
+	s.Get("/items", func() string { return "items" })
+
+	req := httptest.NewRequest("OPTIONS", "/items", nil)
+	req.Header.Set("Origin", "https://any.site")
+	req.Header.Set("Access-Control-Request-Method", "GET")
+	w := httptest.NewRecorder()
+	s.ServeHTTP(w, req)
+	h := w.Header()
+	if w.Code != 204 || h.Get("Access-Control-Allow-Origin") != "*" || h.Get("Access-Control-Allow-Methods") != "GET, POST" ||
+		h.Get("Access-Control-Allow-Headers") != "Content-Type" {
+		t.Errorf("got %d %v", w.Code, h)
+	}
+
+	// without an Origin header OPTIONS keeps answering with the Allow header
+	w = doRequest(s, "OPTIONS", "/items", "")
+	if w.Code != 204 || w.Header().Get("Allow") != "GET, HEAD, OPTIONS" || w.Header().Get("Access-Control-Allow-Origin") != "" {
+		t.Errorf("plain OPTIONS: got %d %v", w.Code, w.Header())
+	}
+}
+
+func TestCORSCredentialsWithAnyOrigin(t *testing.T) {
+	s := newTestServer(t)
+	defer func() {
+		if recover() == nil {
+			t.Error("expected a panic allowing credentials for any origin")
+		}
+	}()
+	s.CORS(CORSConfig{AllowOrigins: []string{"*"}, AllowCredentials: true})
+}
@@ -0,0 +1,137 @@
+package server
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"github.com/widaT/golib/web/session"
+	"html/template"
+	"net/http"
+)
+
+// csrfSessionKey is the session key of the synchronizer token.
+const csrfSessionKey = "_csrf"
+
+var errNoCookieSecret = errors.New("csrf: Config.CookieSecret is not set")
+
+// CSRFConfig configures the CSRF middleware.
+type CSRFConfig struct {
+	// Sessions keeps a synchronizer token per session. When nil, the token
+	// is kept in a cookie signed with Config.CookieSecret and requests must
+	// submit the same token, the double-submit cookie pattern.
+	Sessions *session.Manager
+	// CookieName is the cookie of the double-submit mode, "_csrf" by
+	// default.
+	CookieName string
+	// HeaderName is the request header carrying the token, "X-CSRF-Token"
+	// by default.
+	HeaderName string
+	// FieldName is the form field carrying the token, "_csrf" by default.
+	FieldName string
+}
+
+// CSRF returns a middleware protecting the routes it wraps against
+// cross-site request forgery. Each session, or client in double-submit
+// mode, gets a random token, available to handlers with CSRFToken and
+// CSRFField. Requests with other methods than GET, HEAD, OPTIONS and TRACE
+// must send it back in the header or the form field, or are answered 403
+// Forbidden through the error handler.
+func CSRF(config CSRFConfig) Middleware {
+	if config.CookieName == "" {
+		config.CookieName = "_csrf"
+	}
+	if config.HeaderName == "" {
+		config.HeaderName = "X-CSRF-Token"
+	}
+	if config.FieldName == "" {
+		config.FieldName = "_csrf"
+	}
+	return func(next HandlerFunc) HandlerFunc {
+		return func(ctx *Context) {
+			token, err := config.token(ctx)
+			if err != nil {
+				ctx.HandleError(err)
+				return
+			}
+			ctx.csrfToken, ctx.csrfField = token, config.FieldName
+			ctx.Header().Add("Vary", "Cookie")
+
+			switch ctx.Request.Method {
+			case "GET", "HEAD", "OPTIONS", "TRACE":
+			default:
+				sent := ctx.Request.Header.Get(config.HeaderName)
+				if sent == "" {
+					sent = ctx.PostForm(config.FieldName)
+				}
+				if subtle.ConstantTimeCompare([]byte(sent), []byte(token)) != 1 {
+					ctx.Server.handleError(ctx, HTTPError{Code: http.StatusForbidden, Message: "Invalid CSRF token"})
+					return
+				}
+			}
+			next(ctx)
+		}
+	}
+}
+
+// token returns the token of the client, issuing one if it has none.
+func (c *CSRFConfig) token(ctx *Context) (string, error) {
+	if c.Sessions != nil {
+		store, err := c.Sessions.SessionStart(ctx.ResponseWriter, ctx.Request)
+		if err != nil {
+			return "", err
+		}
+		if token, ok := store.Get(csrfSessionKey).(string); ok && token != "" {
+			return token, nil
+		}
+		token, err := newCSRFToken()
+		if err != nil {
+			return "", err
+		}
+		if err := store.Set(csrfSessionKey, token); err != nil {
+			return "", err
+		}
+		store.SessionRelease(ctx.ResponseWriter)
+		return token, nil
+	}
+
+	if ctx.Server.Config.CookieSecret == "" {
+		return "", errNoCookieSecret
+	}
+	if token, ok := ctx.GetSecureCookie(c.CookieName); ok && token != "" {
+		return token, nil
+	}
+	token, err := newCSRFToken()
+	if err != nil {
+		return "", err
+	}
+	http.SetCookie(ctx.ResponseWriter, &http.Cookie{
+		Name:     c.CookieName,
+		Value:    ctx.signCookieValue(token),
+		Path:     "/",
+		HttpOnly: true,
+		Secure:   ctx.Request.TLS != nil,
+	})
+	return token, nil
+}
+
+func newCSRFToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// CSRFToken returns the CSRF token of the client, set by the CSRF
+// middleware.
+func (ctx *Context) CSRFToken() string {
+	return ctx.csrfToken
+}
+
+// CSRFField returns a hidden input carrying the CSRF token, to be put in
+// the forms of templates.
+func (ctx *Context) CSRFField() template.HTML {
+	return template.HTML(`<input type="hidden" name="` + template.HTMLEscapeString(ctx.csrfField) +
+		`" value="` + template.HTMLEscapeString(ctx.csrfToken) + `">`)
+}
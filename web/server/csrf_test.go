@@ -0,0 +1,101 @@
+package server
+
+import (
+	"github.com/widaT/golib/web/session"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func csrfServer(t *testing.T, config CSRFConfig) *Server {
+	s := newTestServer(t)
+	s.Config.CookieSecret = "secret"
+	g := s.Group("/admin")
+	g.Use(CSRF(config))
+	g.Get("/form", func(ctx *Context) string { return ctx.CSRFToken() })
+	g.Post("/save", func(ctx *Context) string { return "saved" })
+	return s
+}
+
+// csrfSend sends a request with the cookies, returning the response.
+func csrfSend(s *Server, method, path string, form url.Values, header http.Header, cookies []*http.Cookie) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, strings.NewReader(form.Encode()))
+	if form != nil {
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	for _, c := range cookies {
+		req.AddCookie(c)
+	}
+	w := httptest.NewRecorder()
+	s.ServeHTTP(w, req)
+	return w
+}
+
+func testCSRF(t *testing.T, s *Server, cookieName string) {
+	w := csrfSend(s, "GET", "/admin/form", nil, nil, nil)
+	token := w.Body.String()
+	cookies := w.Result().Cookies()
+	if w.Code != 200 || len(token) < 32 || len(cookies) == 0 {
+		t.Fatalf("form: got %d %q %v", w.Code, token, cookies)
+	}
+	if cookieName != "" && cookies[0].Name != cookieName {
+		t.Fatalf("got cookie %v, want %s", cookies, cookieName)
+	}
+
+	// the token is stable for the client
+	if w := csrfSend(s, "GET", "/admin/form", nil, nil, cookies); w.Body.String() != token {
+		t.Errorf("second form: got token %q, want %q", w.Body.String(), token)
+	}
+
+	tests := []struct {
+		name    string
+		form    url.Values
+		header  http.Header
+		cookies []*http.Cookie
+		code    int
+	}{
+		{"form field", url.Values{"_csrf": {token}}, nil, cookies, 200},
+		{"header", nil, http.Header{"X-Csrf-Token": {token}}, cookies, 200},
+		{"missing token", url.Values{"name": {"x"}}, nil, cookies, 403},
+		{"wrong token", url.Values{"_csrf": {"forged"}}, nil, cookies, 403},
+		{"another client", url.Values{"_csrf": {token}}, nil, nil, 403},
+	}
+	for _, test := range tests {
+		w := csrfSend(s, "POST", "/admin/save", test.form, test.header, test.cookies)
+		if w.Code != test.code {
+			t.Errorf("%s: got %d %q, want %d", test.name, w.Code, w.Body.String(), test.code)
+		}
+	}
+}
+
+func TestCSRFSessions(t *testing.T) {
+	manager, err := session.NewManager("memory", session.DefaultManagerConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+	testCSRF(t, csrfServer(t, CSRFConfig{Sessions: manager}), "gxrsgosessionid")
+}
+
+func TestCSRFDoubleSubmit(t *testing.T) {
+	s := csrfServer(t, CSRFConfig{})
+	testCSRF(t, s, "_csrf")
+
+	// an unsigned cookie set by a sibling domain is not accepted
+	forged := []*http.Cookie{{Name: "_csrf", Value: "forged"}}
+	w := csrfSend(s, "POST", "/admin/save", url.Values{"_csrf": {"forged"}}, nil, forged)
+	if w.Code != 403 {
+		t.Errorf("forged cookie: got %d", w.Code)
+	}
+}
+
+func TestCSRFField(t *testing.T) {
+	ctx := &Context{csrfToken: `a"b`, csrfField: "_csrf"}
+	if got := string(ctx.CSRFField()); got != `<input type="hidden" name="_csrf" value="a&#34;b">` {
+		t.Errorf("got %s", got)
+	}
+}
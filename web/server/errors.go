@@ -0,0 +1,102 @@
+package server
+
+import (
+	"net/http"
+	"reflect"
+	"strconv"
+)
+
+var errorType = reflect.TypeOf((*error)(nil)).Elem()
+
+// HTTPError is an error answered with an HTTP status. Handlers may return
+// it, alone or as their last return value, to abort the request:
+//
+//	return server.HTTPError{Code: 422, Message: "invalid email"}
+//
+// Message is sent to the client, Err is the cause which is only logged.
+type HTTPError struct {
+	Code    int
+	Message string
+	Err     error
+}
+
+func (e HTTPError) Error() string {
+	msg := e.Message
+	if msg == "" {
+		msg = http.StatusText(e.Code)
+	}
+	if e.Err != nil {
+		return strconv.Itoa(e.Code) + " " + msg + ": " + e.Err.Error()
+	}
+	return strconv.Itoa(e.Code) + " " + msg
+}
+
+// ErrorHandlerFunc writes the response of an error. err.Message is never
+// empty.
+type ErrorHandlerFunc func(ctx *Context, err HTTPError)
+
+// ErrorHandler registers fn to write the responses with status code, such
+// as the 404 of unmatched paths, the 500 of panicking handlers or the
+// HTTPErrors returned by handlers. The handler registered for code 0
+// handles the codes without a handler of their own.
+func (s *Server) ErrorHandler(code int, fn ErrorHandlerFunc) {
+	if s.errorHandlers == nil {
+		s.errorHandlers = map[int]ErrorHandlerFunc{}
+	}
+	s.errorHandlers[code] = fn
+}
+
+// HandleError answers the request with err through the error handler of
+// its status. Errors other than HTTPError are answered with a 500 and
+// logged.
+func (ctx *Context) HandleError(err error) {
+	var he HTTPError
+	switch e := err.(type) {
+	case HTTPError:
+		he = e
+	case *HTTPError:
+		he = *e
+	default:
+		he = HTTPError{Code: http.StatusInternalServerError, Err: err}
+	}
+	if he.Code == 0 {
+		he.Code = http.StatusInternalServerError
+	}
+	if he.Err != nil || he.Code >= 500 {
+		ctx.Logger().Error("%s %s: %v", ctx.Request.Method, ctx.Request.URL.Path, he)
+	}
+	if he.Message == "" {
+		he.Message = http.StatusText(he.Code)
+	}
+	ctx.Server.handleError(ctx, he)
+}
+
+// handleError writes err with the registered handler, or as plain text.
+func (s *Server) handleError(ctx *Context, err HTTPError) {
+	fn, ok := s.errorHandlers[err.Code]
+	if !ok {
+		fn = s.errorHandlers[0]
+	}
+	if fn != nil {
+		fn(ctx, err)
+		return
+	}
+	ctx.Abort(err.Code, err.Message)
+}
+
+// panicReport logs a recovered panic as a single entry, with the request
+// and the stack of the panicking goroutine.
+func (ctx *Context) panicReport(v interface{}, stack []byte) {
+	ctx.Logger().Error("panic: %v\nrequest: %s %s\nclient: %s\n%s",
+		v, ctx.Request.Method, ctx.Request.URL.RequestURI(), ctx.ClientIP(), stack)
+}
+
+// isNilValue reports whether v, an interface, pointer, map, slice, func or
+// chan, is nil.
+func isNilValue(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Interface, reflect.Ptr, reflect.Map, reflect.Slice, reflect.Func, reflect.Chan:
+		return v.IsNil()
+	}
+	return false
+}
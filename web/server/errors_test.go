@@ -0,0 +1,100 @@
+package server
+
+import (
+	"errors"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestErrorHandlers(t *testing.T) {
+	s := newTestServer(t)
+	s.ErrorHandler(404, func(ctx *Context, err HTTPError) {
+		ctx.JSON(err.Code, map[string]string{"error": err.Message})
+	})
+	s.ErrorHandler(0, func(ctx *Context, err HTTPError) {
+		ctx.Abort(err.Code, "oops: "+err.Message)
+	})
+	s.Get("/invalid", func() error {
+		return HTTPError{Code: 422, Message: "invalid email"}
+	})
+	s.Get("/gone", func() (string, error) {
+		return "", &HTTPError{Code: 410}
+	})
+	s.Get("/ok", func() (string, error) { return "fine", nil })
+	s.Get("/fail", func() error { return errors.New("database down") })
+	s.Get("/panic", func() string { panic("boom") })
+
+	tests := []struct {
+		path string
+		code int
+		body string
+	}{
+		{"/missing", 404, `{"error":"Page not found"}`},
+		{"/invalid", 422, "oops: invalid email"},
+		{"/gone", 410, "oops: Gone"},
+		{"/ok", 200, "fine"},
+		{"/fail", 500, "oops: Internal Server Error"},
+		{"/panic", 500, "oops: Server Error"},
+	}
+	for _, test := range tests {
+		w := doRequest(s, "GET", test.path, "")
+		if w.Code != test.code || strings.TrimSpace(w.Body.String()) != test.body {
+			t.Errorf("%s: got %d %q, want %d %q", test.path, w.Code, w.Body.String(), test.code, test.body)
+		}
+	}
+}
+
+func TestPanicReport(t *testing.T) {
+	s := newTestServer(t)
+	s.Get("/crash", func() string { panic("kaboom") })
+	w := doRequest(s, "GET", "/crash?x=1", "")
+	if w.Code != 500 || w.Body.String() != "Server Error" {
+		t.Fatalf("got %d %q", w.Code, w.Body.String())
+	}
+
+	s.Logger.Flush()
+	data, err := ioutil.ReadFile(filepath.Join(testDir, "access.log"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	id := w.Header().Get("X-Request-ID")
+	i := strings.Index(string(data), "["+id+"] [E] panic: kaboom")
+	if i < 0 {
+		t.Fatalf("no panic report for request %s in %q", id, data)
+	}
+	report := string(data[i:])
+	for _, want := range []string{"request: GET /crash?x=1", "errors_test.go"} {
+		if !strings.Contains(report, want) {
+			t.Errorf("panic report misses %q:\n%s", want, report)
+		}
+	}
+}
+
+func TestPanicOutsideHandler(t *testing.T) {
+	s := newTestServer(t)
+	s.Use(func(next HandlerFunc) HandlerFunc {
+		return func(ctx *Context) {
+			if ctx.Request.URL.Path == "/middleware" {
+				panic("middleware")
+			}
+			next(ctx)
+		}
+	})
+	s.AddFilter("^/filter", func(ctx *Context) bool { panic("filter") })
+	g := s.Group("/group")
+	g.Use(func(next HandlerFunc) HandlerFunc {
+		return func(ctx *Context) { panic("group") }
+	})
+	s.Get("/middleware", func() string { return "unreachable" })
+	s.Get("/filter", func() string { return "unreachable" })
+	g.Get("/x", func() string { return "unreachable" })
+
+	for _, path := range []string{"/middleware", "/filter", "/group/x"} {
+		w := doRequest(s, "GET", path, "")
+		if w.Code != 500 || w.Body.String() != "Server Error" {
+			t.Errorf("%s: got %d %q", path, w.Code, w.Body.String())
+		}
+	}
+}
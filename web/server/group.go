@@ -0,0 +1,136 @@
+package server
+
+import (
+	"net/http"
+	"strings"
+)
+
+// RouteGroup is a set of routes sharing a path prefix, filters and
+// middlewares. Routes and filters added to a group are relative to its
+// prefix. Groups nest to any depth, a sub group inheriting the prefix,
+// filters and middlewares of its parent.
+type RouteGroup struct {
+	server      *Server
+	parent      *RouteGroup
+	prefix      string
+	middlewares []Middleware
+}
+
+// Group returns a sub-router mounted under prefix. The given filters run
+// before every request under prefix, in registration order with the other
+// filters of the server.
+func (s *Server) Group(prefix string, filters ...FilerFun) *RouteGroup {
+	return s.newGroup(nil, joinPath("", prefix), filters)
+}
+
+func (s *Server) newGroup(parent *RouteGroup, prefix string, filters []FilerFun) *RouteGroup {
+	g := &RouteGroup{server: s, parent: parent, prefix: prefix}
+	for _, fn := range filters {
+		s.addFilter(g.prefix, "", fn)
+	}
+	return g
+}
+
+// Group returns a sub group of g mounted under prefix.
+func (g *RouteGroup) Group(prefix string, filters ...FilerFun) *RouteGroup {
+	return g.server.newGroup(g, joinPath(g.prefix, prefix), filters)
+}
+
+// Use appends middlewares wrapping the routes of g and of its sub groups.
+// They run inside the server middlewares and filters.
+func (g *RouteGroup) Use(middlewares ...Middleware) {
+	g.middlewares = append(g.middlewares, middlewares...)
+	// rebuild the chains of the routes already added
+	for _, routes := range g.server.routes {
+		for _, r := range routes {
+			if r.in(g) {
+				r.build()
+			}
+		}
+	}
+}
+
+func (g *RouteGroup) addRoute(route string, method string, handler interface{}, middlewares []Middleware) {
+	r := g.server.addRoute(joinPath(g.prefix, route), method, handler, middlewares)
+	r.group = g
+	r.build()
+}
+
+// Prefix returns the full path prefix of g.
+func (g *RouteGroup) Prefix() string {
+	return g.prefix
+}
+
+// AddFilter runs fn before requests under the group whose path, relative
+// to the group prefix, matches the regex r.
+func (g *RouteGroup) AddFilter(r string, fn FilerFun) {
+	g.server.addFilter(g.prefix, r, fn)
+}
+
+// AddRoute binds handler to route for every HTTP method.
+func (g *RouteGroup) AddRoute(route string, handler interface{}, middlewares ...Middleware) {
+	g.addRoute(route, anyMethod, handler, middlewares)
+}
+
+// Handler adds a custom http.Handler to the group.
+func (g *RouteGroup) Handler(route string, httpHandler http.Handler, middlewares ...Middleware) {
+	g.addRoute(route, anyMethod, httpHandler, middlewares)
+}
+
+// Match binds handler to route for the given HTTP method.
+func (g *RouteGroup) Match(method string, route string, handler interface{}, middlewares ...Middleware) {
+	g.addRoute(route, strings.ToUpper(method), handler, middlewares)
+}
+
+// Get adds a handler for the 'GET' http method to the group.
+func (g *RouteGroup) Get(route string, handler interface{}, middlewares ...Middleware) {
+	g.addRoute(route, "GET", handler, middlewares)
+}
+
+// Post adds a handler for the 'POST' http method to the group.
+func (g *RouteGroup) Post(route string, handler interface{}, middlewares ...Middleware) {
+	g.addRoute(route, "POST", handler, middlewares)
+}
+
+// Put adds a handler for the 'PUT' http method to the group.
+func (g *RouteGroup) Put(route string, handler interface{}, middlewares ...Middleware) {
+	g.addRoute(route, "PUT", handler, middlewares)
+}
+
+// Delete adds a handler for the 'DELETE' http method to the group.
+func (g *RouteGroup) Delete(route string, handler interface{}, middlewares ...Middleware) {
+	g.addRoute(route, "DELETE", handler, middlewares)
+}
+
+// Patch adds a handler for the 'PATCH' http method to the group.
+func (g *RouteGroup) Patch(route string, handler interface{}, middlewares ...Middleware) {
+	g.addRoute(route, "PATCH", handler, middlewares)
+}
+
+// Options adds a handler for the 'OPTIONS' http method to the group.
+func (g *RouteGroup) Options(route string, handler interface{}, middlewares ...Middleware) {
+	g.addRoute(route, "OPTIONS", handler, middlewares)
+}
+
+// Any adds a handler for every http method to the group.
+func (g *RouteGroup) Any(route string, handler interface{}, middlewares ...Middleware) {
+	g.addRoute(route, anyMethod, handler, middlewares)
+}
+
+// joinPath joins a prefix and a route into a clean absolute path.
+func joinPath(prefix string, route string) string {
+	segments := append(splitPath(prefix), splitPath(route)...)
+	return "/" + strings.Join(segments, "/")
+}
+
+// hasPathPrefix reports whether path is prefix or lies below it,
+// so that "/api/v1" matches "/api/v1/users" but not "/api/v10".
+func hasPathPrefix(path string, prefix string) bool {
+	if prefix == "/" {
+		return true
+	}
+	if !strings.HasPrefix(path, prefix) {
+		return false
+	}
+	return len(path) == len(prefix) || path[len(prefix)] == '/'
+}
@@ -0,0 +1,57 @@
+package server
+
+// HandlerFunc is the signature of the handlers composed by middlewares.
+type HandlerFunc func(*Context)
+
+// Middleware wraps a handler. Code before calling next runs before the
+// wrapped handler, code after it runs once the handler has returned.
+// A middleware that does not call next stops the request.
+type Middleware func(next HandlerFunc) HandlerFunc
+
+// Use appends middlewares wrapping every request served by s, including
+// the filters and requests that match no route.
+func (s *Server) Use(middlewares ...Middleware) {
+	s.middlewares = append(s.middlewares, middlewares...)
+	s.chained = chain(s.dispatch, s.middlewares)
+}
+
+// FilterMiddleware adapts a filter into a middleware which stops the
+// request when fn returns false.
+func FilterMiddleware(fn FilerFun) Middleware {
+	return func(next HandlerFunc) HandlerFunc {
+		return func(ctx *Context) {
+			if fn(ctx) {
+				next(ctx)
+			}
+		}
+	}
+}
+
+// chain wraps h in middlewares, the first one being the outermost.
+func chain(h HandlerFunc, middlewares []Middleware) HandlerFunc {
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		h = middlewares[i](h)
+	}
+	return h
+}
+
+// build wraps the handler of the route in its middlewares, then in those
+// of its groups from the innermost to the outermost one, so requests run
+// the composed chain without rebuilding it.
+func (r *route) build() {
+	h := chain(r.handler, r.middlewares)
+	for g := r.group; g != nil; g = g.parent {
+		h = chain(h, g.middlewares)
+	}
+	r.chained = h
+}
+
+// in reports whether the route belongs to g or to one of its sub groups.
+func (r *route) in(g *RouteGroup) bool {
+	for rg := r.group; rg != nil; rg = rg.parent {
+		if rg == g {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,91 @@
+package server
+
+import (
+	"strings"
+	"testing"
+)
+
+func traceMiddleware(trace *[]string, name string) Middleware {
+	return func(next HandlerFunc) HandlerFunc {
+		return func(ctx *Context) {
+			*trace = append(*trace, name+">")
+			next(ctx)
+			*trace = append(*trace, "<"+name)
+		}
+	}
+}
+
+func TestMiddlewareOrder(t *testing.T) {
+	s := newTestServer(t)
+	var trace []string
+	s.Use(traceMiddleware(&trace, "global"))
+	s.AddFilter("^/api", func(ctx *Context) bool {
+		trace = append(trace, "filter")
+		return true
+	})
+	api := s.Group("/api")
+	api.Use(traceMiddleware(&trace, "api"))
+	v1 := api.Group("/v1")
+	v1.Use(traceMiddleware(&trace, "v1"))
+	v1.Get("/ping", func() string {
+		trace = append(trace, "handler")
+		return "pong"
+	}, traceMiddleware(&trace, "route"))
+
+	w := doRequest(s, "GET", "/api/v1/ping", "")
+	if w.Body.String() != "pong" {
+		t.Errorf("expected pong got %q", w.Body.String())
+	}
+	expected := "global>,filter,api>,v1>,route>,handler,<route,<v1,<api,<global"
+	if got := strings.Join(trace, ","); got != expected {
+		t.Errorf("expected %s got %s", expected, got)
+	}
+
+	trace = nil
+	if w := doRequest(s, "GET", "/missing", ""); w.Code != 404 {
+		t.Errorf("expected 404 got %d", w.Code)
+	}
+	if got := strings.Join(trace, ","); got != "global>,<global" {
+		t.Errorf("expected global middleware around 404 got %s", got)
+	}
+}
+
+func TestFilterMiddleware(t *testing.T) {
+	s := newTestServer(t)
+	deny := FilterMiddleware(func(ctx *Context) bool {
+		ctx.Abort(401, "denied")
+		return false
+	})
+	s.Get("/private", func() string { return "private" }, deny)
+	if w := doRequest(s, "GET", "/private", ""); w.Code != 401 || w.Body.String() != "denied" {
+		t.Errorf("expected 401 denied got %d %q", w.Code, w.Body.String())
+	}
+}
+
+func TestMiddlewareChainBuiltOnce(t *testing.T) {
+	s := newTestServer(t)
+	var built int
+	counting := func(next HandlerFunc) HandlerFunc {
+		built++
+		return next
+	}
+	var trace []string
+	api := s.Group("/api")
+	api.Get("/ping", func() string { return "pong" }, counting)
+	s.Use(counting)
+	// middlewares added to a group after its routes still wrap them
+	api.Use(traceMiddleware(&trace, "api"))
+
+	before := built
+	for i := 0; i < 3; i++ {
+		if w := doRequest(s, "GET", "/api/ping", ""); w.Body.String() != "pong" {
+			t.Fatalf("expected pong got %q", w.Body.String())
+		}
+	}
+	if built != before {
+		t.Errorf("expected the chains to be built at registration, built %d more times", built-before)
+	}
+	if got := strings.Join(trace, ","); got != "api>,<api,api>,<api,api>,<api" {
+		t.Errorf("expected the group middleware around each request got %s", got)
+	}
+}
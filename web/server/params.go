@@ -0,0 +1,183 @@
+package server
+
+import (
+	"mime"
+	"net/http"
+	"net/url"
+	"strconv"
+)
+
+// defaultMaxMemory is the default part of multipart bodies kept in
+// memory, the remaining file parts are stored on disk.
+const defaultMaxMemory = 32 << 20
+
+// multipartOverhead is the room left for the form fields and the part
+// headers of multipart bodies on top of ServerConfig.MaxUploadSize.
+const multipartOverhead = 64 << 10
+
+// parseForm parses the query string and the form body of the request
+// once. routeHandler only parses the cheap url-encoded bodies; multipart
+// bodies are parsed by dispatch when a route other than an http.Handler
+// matched, or when the server middlewares need them, and their values are
+// then added to the Params.
+func (ctx *Context) parseForm() {
+	if ctx.formParsed {
+		return
+	}
+	ctx.formParsed = true
+	req := ctx.Request
+	if !isMultipart(req.Header.Get("Content-Type")) {
+		req.ParseForm()
+		return
+	}
+	config := ctx.Server.Config
+	maxMemory := config.MaxMultipartMemory
+	if maxMemory <= 0 {
+		maxMemory = defaultMaxMemory
+	}
+	var upload *limitedBody
+	if config.MaxUploadSize > 0 && req.Body != nil {
+		limit := config.MaxUploadSize + multipartOverhead
+		upload = &limitedBody{ReadCloser: http.MaxBytesReader(ctx.ResponseWriter, req.Body, limit), limit: limit}
+		req.Body = upload
+	}
+	req.ParseMultipartForm(maxMemory)
+	ctx.tooLarge = upload != nil && upload.tooLarge || ctx.body != nil && ctx.body.tooLarge
+	if req.MultipartForm == nil {
+		return
+	}
+	for k, v := range req.MultipartForm.Value {
+		if _, ok := ctx.Params[k]; !ok && len(v) > 0 {
+			ctx.Params[k] = v[0]
+		}
+	}
+}
+
+func isMultipart(contentType string) bool {
+	ctype, _, _ := mime.ParseMediaType(contentType)
+	return ctype == "multipart/form-data" || ctype == "multipart/mixed"
+}
+
+// queryValues returns the parsed URL query, cached on the context.
+func (ctx *Context) queryValues() url.Values {
+	if ctx.query == nil {
+		ctx.query = ctx.Request.URL.Query()
+	}
+	return ctx.query
+}
+
+// Query returns the first value of the URL query parameter key.
+func (ctx *Context) Query(key string) string {
+	return ctx.queryValues().Get(key)
+}
+
+// QueryAll returns all the values of the URL query parameter key.
+func (ctx *Context) QueryAll(key string) []string {
+	return ctx.queryValues()[key]
+}
+
+// PostForm returns the first value of key in the request body, ignoring
+// the URL query.
+func (ctx *Context) PostForm(key string) string {
+	values := ctx.PostFormAll(key)
+	if len(values) == 0 {
+		return ""
+	}
+	return values[0]
+}
+
+// PostFormAll returns all the values of key in the request body.
+func (ctx *Context) PostFormAll(key string) []string {
+	ctx.parseForm()
+	if values := ctx.Request.PostForm[key]; len(values) > 0 {
+		return values
+	}
+	if ctx.Request.MultipartForm != nil {
+		return ctx.Request.MultipartForm.Value[key]
+	}
+	return nil
+}
+
+// Param returns the path parameter key, or else the first value of key
+// in the request body or the URL query.
+func (ctx *Context) Param(key string) string {
+	value, _ := ctx.param(key)
+	return value
+}
+
+// ParamAll returns all the values of key in the request body and the
+// URL query, body values first.
+func (ctx *Context) ParamAll(key string) []string {
+	ctx.parseForm()
+	return ctx.Request.Form[key]
+}
+
+func (ctx *Context) param(key string) (string, bool) {
+	if value, ok := ctx.pathParams[key]; ok {
+		return value, true
+	}
+	ctx.parseForm()
+	if values := ctx.Request.Form[key]; len(values) > 0 {
+		return values[0], true
+	}
+	return "", false
+}
+
+// ParamInt returns the parameter key as an int, or def when it is
+// missing or malformed.
+func (ctx *Context) ParamInt(key string, def int) int {
+	value, ok := ctx.param(key)
+	if !ok {
+		return def
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		return def
+	}
+	return n
+}
+
+// ParamInt64 returns the parameter key as an int64, or def when it is
+// missing or malformed.
+func (ctx *Context) ParamInt64(key string, def int64) int64 {
+	value, ok := ctx.param(key)
+	if !ok {
+		return def
+	}
+	n, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return def
+	}
+	return n
+}
+
+// ParamFloat returns the parameter key as a float64, or def when it is
+// missing or malformed.
+func (ctx *Context) ParamFloat(key string, def float64) float64 {
+	value, ok := ctx.param(key)
+	if !ok {
+		return def
+	}
+	f, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return def
+	}
+	return f
+}
+
+// ParamBool returns the parameter key as a bool, or def when it is
+// missing or malformed. "on", as sent by checkboxes, is true.
+func (ctx *Context) ParamBool(key string, def bool) bool {
+	value, ok := ctx.param(key)
+	if !ok {
+		return def
+	}
+	if value == "on" {
+		return true
+	}
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		return def
+	}
+	return b
+}
@@ -0,0 +1,81 @@
+package server
+
+import (
+	"bytes"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestParams(t *testing.T) {
+	s := newTestServer(t)
+	var ctx *Context
+	s.Post("/items/:id", func(c *Context) { ctx = c })
+
+	doRequest(s, "POST", "/items/7?tag=a&tag=b&page=x&debug=on", "tag=c&limit=20")
+	if got := strings.Join(ctx.QueryAll("tag"), ","); got != "a,b" {
+		t.Errorf("QueryAll: expected a,b got %s", got)
+	}
+	if got := ctx.PostForm("tag"); got != "c" {
+		t.Errorf("PostForm: expected c got %s", got)
+	}
+	if got := ctx.Query("limit"); got != "" {
+		t.Errorf("Query should ignore the body, got %s", got)
+	}
+	if got := strings.Join(ctx.ParamAll("tag"), ","); got != "c,a,b" {
+		t.Errorf("ParamAll: expected c,a,b got %s", got)
+	}
+	if got := ctx.ParamInt("id", 0); got != 7 {
+		t.Errorf("ParamInt id: expected 7 got %d", got)
+	}
+	if got := ctx.ParamInt("limit", 10); got != 20 {
+		t.Errorf("ParamInt limit: expected 20 got %d", got)
+	}
+	if got := ctx.ParamInt("page", 1); got != 1 {
+		t.Errorf("ParamInt page: expected default 1 got %d", got)
+	}
+	if !ctx.ParamBool("debug", false) || ctx.ParamBool("missing", false) {
+		t.Errorf("ParamBool: unexpected values")
+	}
+	if ctx.Params["tag"] != "c" {
+		t.Errorf("Params: expected c got %s", ctx.Params["tag"])
+	}
+}
+
+func TestParamsMultipart(t *testing.T) {
+	s := newTestServer(t)
+	var params map[string]string
+	s.Post("/items", func(ctx *Context) string {
+		params = ctx.Params
+		return ctx.Params["name"]
+	})
+	var raw *http.Request
+	s.Handler("/raw", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { raw = r }))
+
+	post := func(path string) *httptest.ResponseRecorder {
+		var buf bytes.Buffer
+		mw := multipart.NewWriter(&buf)
+		mw.WriteField("name", "bob")
+		mw.Close()
+		req := httptest.NewRequest("POST", path, &buf)
+		req.Header.Set("Content-Type", mw.FormDataContentType())
+		w := httptest.NewRecorder()
+		s.ServeHTTP(w, req)
+		return w
+	}
+
+	if w := post("/items?page=2"); w.Body.String() != "bob" {
+		t.Errorf("expected the multipart field in Params got %q", w.Body.String())
+	}
+	if params["page"] != "2" || params["name"] != "bob" {
+		t.Errorf("expected the query and the body in Params got %v", params)
+	}
+
+	// http.Handlers read the body themselves, it is left unparsed
+	post("/raw")
+	if raw == nil || raw.MultipartForm != nil {
+		t.Error("expected the multipart body of an http.Handler to be left unparsed")
+	}
+}
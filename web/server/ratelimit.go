@@ -0,0 +1,263 @@
+package server
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// RateLimitAlgorithm is the way requests are counted by RateLimit.
+type RateLimitAlgorithm int
+
+const (
+	// TokenBucket allows bursts of Limit requests, refilled at the rate of
+	// Limit per Window.
+	TokenBucket RateLimitAlgorithm = iota
+	// SlidingWindow allows Limit requests in any Window, estimated from the
+	// counts of the current and the previous fixed windows.
+	SlidingWindow
+)
+
+// RateLimitConfig configures the RateLimit middleware.
+type RateLimitConfig struct {
+	// Limit is the number of requests allowed per Window, both must be
+	// positive.
+	Limit  int
+	Window time.Duration
+	// Algorithm is TokenBucket by default.
+	Algorithm RateLimitAlgorithm
+	// Store keeps the counts, an in-memory store by default. Share a Redis
+	// store to limit the requests across several servers.
+	Store RateLimitStore
+	// KeyFunc returns the key requests are counted by, the client IP by
+	// default, see RateLimitByHeader. An empty key is not limited.
+	KeyFunc func(*Context) string
+}
+
+// RateLimitResult is the outcome of counting a request.
+type RateLimitResult struct {
+	Allowed   bool
+	Limit     int
+	Remaining int
+	// Reset is the time until the limit is fully available again.
+	Reset time.Duration
+	// RetryAfter is the time until a denied request would be allowed.
+	RetryAfter time.Duration
+}
+
+// RateLimitStore keeps the state of rate limits.
+type RateLimitStore interface {
+	// Take counts a request for key, returning whether it is allowed.
+	Take(key string, algorithm RateLimitAlgorithm, limit int, window time.Duration) (RateLimitResult, error)
+}
+
+// RateLimit returns a middleware limiting the requests per key, answering
+// 429 Too Many Requests through the error handler past the limit. The
+// responses carry the RateLimit-Limit, RateLimit-Remaining and
+// RateLimit-Reset headers, and Retry-After when denied. Requests are let
+// through when the store fails. It panics when Limit or Window is not
+// positive.
+func RateLimit(config RateLimitConfig) Middleware {
+	if config.Limit <= 0 || config.Window <= 0 {
+		panic("ratelimit: Limit and Window must be positive")
+	}
+	if config.Store == nil {
+		config.Store = NewMemoryRateLimitStore()
+	}
+	if config.KeyFunc == nil {
+		config.KeyFunc = (*Context).ClientIP
+	}
+	policy := strconv.Itoa(config.Limit) + ";w=" + strconv.Itoa(int(config.Window/time.Second))
+	prefix := strconv.Itoa(int(config.Algorithm)) + ":" + strconv.Itoa(config.Limit) + ":" +
+		strconv.FormatInt(int64(config.Window/time.Millisecond), 10) + ":"
+
+	return func(next HandlerFunc) HandlerFunc {
+		return func(ctx *Context) {
+			key := config.KeyFunc(ctx)
+			if key == "" {
+				next(ctx)
+				return
+			}
+			res, err := config.Store.Take(prefix+key, config.Algorithm, config.Limit, config.Window)
+			if err != nil {
+				ctx.Logger().Error("rate limit: %v", err)
+				next(ctx)
+				return
+			}
+
+			h := ctx.Header()
+			h.Set("RateLimit-Policy", policy)
+			h.Set("RateLimit-Limit", strconv.Itoa(res.Limit))
+			h.Set("RateLimit-Remaining", strconv.Itoa(res.Remaining))
+			h.Set("RateLimit-Reset", strconv.Itoa(ceilSeconds(res.Reset)))
+			if !res.Allowed {
+				h.Set("Retry-After", strconv.Itoa(ceilSeconds(res.RetryAfter)))
+				ctx.Server.handleError(ctx, HTTPError{Code: http.StatusTooManyRequests, Message: "Too Many Requests"})
+				return
+			}
+			next(ctx)
+		}
+	}
+}
+
+// RateLimitByHeader returns a KeyFunc counting the requests by the value
+// of the header name, such as an API key, and by client IP without it.
+func RateLimitByHeader(name string) func(*Context) string {
+	return func(ctx *Context) string {
+		if v := ctx.Request.Header.Get(name); v != "" {
+			return name + ":" + v
+		}
+		return ctx.ClientIP()
+	}
+}
+
+func ceilSeconds(d time.Duration) int {
+	return int((d + time.Second - 1) / time.Second)
+}
+
+// bucketResult computes the result of a token bucket holding tokens after
+// the request was counted.
+func bucketResult(allowed bool, tokens float64, limit int, window time.Duration) RateLimitResult {
+	perToken := float64(window) / float64(limit)
+	res := RateLimitResult{
+		Allowed:   allowed,
+		Limit:     limit,
+		Remaining: int(math.Floor(tokens)),
+		Reset:     time.Duration((float64(limit) - tokens) * perToken),
+	}
+	if !allowed {
+		res.RetryAfter = time.Duration((1 - tokens) * perToken)
+	}
+	return res
+}
+
+// windowResult computes the result of a sliding window holding cur
+// requests in the current fixed window, elapsed for, and prev in the
+// previous one, after the request was counted.
+func windowResult(allowed bool, cur, prev int, elapsed time.Duration, limit int, window time.Duration) RateLimitResult {
+	weight := float64(window-elapsed) / float64(window)
+	estimate := float64(prev)*weight + float64(cur)
+	res := RateLimitResult{
+		Allowed:   allowed,
+		Limit:     limit,
+		Remaining: int(math.Max(0, math.Floor(float64(limit)-estimate))),
+		Reset:     window - elapsed,
+	}
+	if !allowed {
+		if cur >= limit {
+			// the current count weighs as the previous one in the next window
+			res.RetryAfter = window - elapsed + time.Duration(float64(window)*(1-float64(limit-1)/float64(cur)))
+		} else {
+			// when the weighted previous count leaves room for a request
+			at := time.Duration(float64(window) * (1 - float64(limit-1-cur)/float64(prev)))
+			res.RetryAfter = at - elapsed
+		}
+	}
+	return res
+}
+
+// windowAllows reports whether a request fits in a sliding window.
+func windowAllows(cur, prev int, elapsed time.Duration, limit int, window time.Duration) bool {
+	weight := float64(window-elapsed) / float64(window)
+	return float64(prev)*weight+float64(cur)+1 <= float64(limit)
+}
+
+// MemoryRateLimitStore is a RateLimitStore local to the process.
+type MemoryRateLimitStore struct {
+	mu        sync.Mutex
+	buckets   map[string]*bucketState
+	windows   map[string]*windowState
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+type bucketState struct {
+	tokens float64
+	last   time.Time
+	window time.Duration
+}
+
+type windowState struct {
+	index      int64
+	cur, prev  int
+	window     time.Duration
+	lastUpdate time.Time
+}
+
+// memorySweepInterval is how often idle limits are dropped.
+const memorySweepInterval = time.Minute
+
+// NewMemoryRateLimitStore returns an empty in-memory store.
+func NewMemoryRateLimitStore() *MemoryRateLimitStore {
+	return &MemoryRateLimitStore{
+		buckets:   map[string]*bucketState{},
+		windows:   map[string]*windowState{},
+		lastSweep: time.Now(),
+		now:       time.Now,
+	}
+}
+
+// Take counts a request for key.
+func (m *MemoryRateLimitStore) Take(key string, algorithm RateLimitAlgorithm, limit int, window time.Duration) (RateLimitResult, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := m.now()
+	m.sweep(now)
+
+	if algorithm == SlidingWindow {
+		st, ok := m.windows[key]
+		if !ok {
+			st = &windowState{window: window}
+			m.windows[key] = st
+		}
+		index := now.UnixNano() / int64(window)
+		switch {
+		case index == st.index+1:
+			st.prev, st.cur = st.cur, 0
+		case index != st.index:
+			st.prev, st.cur = 0, 0
+		}
+		st.index, st.lastUpdate = index, now
+		elapsed := time.Duration(now.UnixNano() - index*int64(window))
+		allowed := windowAllows(st.cur, st.prev, elapsed, limit, window)
+		if allowed {
+			st.cur++
+		}
+		return windowResult(allowed, st.cur, st.prev, elapsed, limit, window), nil
+	}
+
+	st, ok := m.buckets[key]
+	if !ok {
+		st = &bucketState{tokens: float64(limit), last: now, window: window}
+		m.buckets[key] = st
+	}
+	refill := float64(now.Sub(st.last)) * float64(limit) / float64(window)
+	st.tokens = math.Min(float64(limit), st.tokens+refill)
+	st.last = now
+	allowed := st.tokens >= 1
+	if allowed {
+		st.tokens--
+	}
+	return bucketResult(allowed, st.tokens, limit, window), nil
+}
+
+// sweep drops the limits idle long enough to be back to their initial
+// state.
+func (m *MemoryRateLimitStore) sweep(now time.Time) {
+	if now.Sub(m.lastSweep) < memorySweepInterval {
+		return
+	}
+	m.lastSweep = now
+	for key, st := range m.buckets {
+		if now.Sub(st.last) >= st.window {
+			delete(m.buckets, key)
+		}
+	}
+	for key, st := range m.windows {
+		if now.Sub(st.lastUpdate) >= 2*st.window {
+			delete(m.windows, key)
+		}
+	}
+}
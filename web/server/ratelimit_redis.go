@@ -0,0 +1,114 @@
+package server
+
+import (
+	"github.com/garyburd/redigo/redis"
+	"strconv"
+	"time"
+)
+
+// tokenBucketScript refills and takes a token from the bucket stored in
+// the hash KEYS[1], returning whether it was allowed and the tokens left.
+var tokenBucketScript = redis.NewScript(1, `
+local limit = tonumber(ARGV[1])
+local window = tonumber(ARGV[2])
+local now = tonumber(ARGV[3])
+local state = redis.call('HMGET', KEYS[1], 'tokens', 'last')
+local tokens = tonumber(state[1]) or limit
+local last = tonumber(state[2]) or now
+if now > last then
+	tokens = math.min(limit, tokens + (now - last) * limit / window)
+else
+	now = last
+end
+local allowed = 0
+if tokens >= 1 then
+	tokens = tokens - 1
+	allowed = 1
+end
+redis.call('HMSET', KEYS[1], 'tokens', tostring(tokens), 'last', now)
+redis.call('PEXPIRE', KEYS[1], window)
+return {allowed, tostring(tokens)}
+`)
+
+// slidingWindowScript counts a request in the sliding window stored in the
+// hash KEYS[1], returning whether it was allowed and the counts of the
+// current and previous fixed windows.
+var slidingWindowScript = redis.NewScript(1, `
+local limit = tonumber(ARGV[1])
+local window = tonumber(ARGV[2])
+local now = tonumber(ARGV[3])
+local index = math.floor(now / window)
+local state = redis.call('HMGET', KEYS[1], 'index', 'cur', 'prev')
+local last = tonumber(state[1])
+local cur = tonumber(state[2]) or 0
+local prev = tonumber(state[3]) or 0
+if last == nil or index > last + 1 then
+	cur, prev = 0, 0
+elseif index == last + 1 then
+	cur, prev = 0, cur
+elseif index < last then
+	index = last
+end
+local elapsed = now - index * window
+local allowed = 0
+if prev * (window - elapsed) / window + cur + 1 <= limit then
+	cur = cur + 1
+	allowed = 1
+end
+redis.call('HMSET', KEYS[1], 'index', index, 'cur', cur, 'prev', prev)
+redis.call('PEXPIRE', KEYS[1], 2 * window)
+return {allowed, cur, prev, elapsed}
+`)
+
+// RedisRateLimitStore is a RateLimitStore shared by the servers using the
+// same Redis, such as the pool of cache.Cache. Each key is counted
+// atomically by a Lua script.
+type RedisRateLimitStore struct {
+	pool   *redis.Pool
+	prefix string
+}
+
+// NewRedisRateLimitStore returns a store keeping its counts in Redis
+// hashes named after prefix, "ratelimit:" when empty.
+func NewRedisRateLimitStore(pool *redis.Pool, prefix string) *RedisRateLimitStore {
+	if prefix == "" {
+		prefix = "ratelimit:"
+	}
+	return &RedisRateLimitStore{pool: pool, prefix: prefix}
+}
+
+// Take counts a request for key.
+func (r *RedisRateLimitStore) Take(key string, algorithm RateLimitAlgorithm, limit int, window time.Duration) (RateLimitResult, error) {
+	c := r.pool.Get()
+	defer c.Close()
+
+	ms := int64(window / time.Millisecond)
+	now := time.Now().UnixNano() / int64(time.Millisecond)
+	script := tokenBucketScript
+	if algorithm == SlidingWindow {
+		script = slidingWindowScript
+	}
+	values, err := redis.Values(script.Do(c, r.prefix+key, limit, ms, now))
+	if err != nil {
+		return RateLimitResult{}, err
+	}
+
+	if algorithm == SlidingWindow {
+		var allowed, cur, prev int
+		var elapsed int64
+		if _, err := redis.Scan(values, &allowed, &cur, &prev, &elapsed); err != nil {
+			return RateLimitResult{}, err
+		}
+		return windowResult(allowed == 1, cur, prev, time.Duration(elapsed)*time.Millisecond, limit, window), nil
+	}
+	var allowed int
+	var tokens string
+	if _, err := redis.Scan(values, &allowed, &tokens); err != nil {
+		return RateLimitResult{}, err
+	}
+	t, err := strconv.ParseFloat(tokens, 64)
+	if err != nil {
+		return RateLimitResult{}, err
+	}
+	return bucketResult(allowed == 1, t, limit, window), nil
+}
@@ -0,0 +1,68 @@
+package server
+
+import (
+	"github.com/garyburd/redigo/redis"
+	"os"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// newTestRedisStore returns a store on the Redis of $REDIS_ADDR, or of
+// 127.0.0.1:6379, skipping the test when none is reachable.
+func newTestRedisStore(t *testing.T) (*RedisRateLimitStore, func()) {
+	addr := os.Getenv("REDIS_ADDR")
+	if addr == "" {
+		addr = "127.0.0.1:6379"
+	}
+	pool := &redis.Pool{Dial: func() (redis.Conn, error) {
+		return redis.Dial("tcp", addr, redis.DialConnectTimeout(time.Second))
+	}}
+	c := pool.Get()
+	if _, err := c.Do("PING"); err != nil {
+		c.Close()
+		pool.Close()
+		t.Skipf("no Redis at %s: %v", addr, err)
+	}
+	c.Close()
+
+	prefix := "ratelimit-test:" + strconv.FormatInt(time.Now().UnixNano(), 10) + ":"
+	cleanup := func() {
+		c := pool.Get()
+		keys, _ := redis.Strings(c.Do("KEYS", prefix+"*"))
+		for _, key := range keys {
+			c.Do("DEL", key)
+		}
+		c.Close()
+		pool.Close()
+	}
+	return NewRedisRateLimitStore(pool, prefix), cleanup
+}
+
+func TestRedisRateLimitStore(t *testing.T) {
+	store, cleanup := newTestRedisStore(t)
+	defer cleanup()
+
+	for _, algorithm := range []RateLimitAlgorithm{TokenBucket, SlidingWindow} {
+		key := "k" + strconv.Itoa(int(algorithm))
+		for i := 0; i < 3; i++ {
+			res, err := store.Take(key, algorithm, 3, time.Minute)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !res.Allowed || res.Limit != 3 || res.Remaining != 2-i {
+				t.Fatalf("algorithm %d, request %d: got %+v", algorithm, i, res)
+			}
+		}
+		res, err := store.Take(key, algorithm, 3, time.Minute)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if res.Allowed || res.Remaining != 0 || res.RetryAfter <= 0 || res.Reset <= 0 {
+			t.Errorf("algorithm %d, over the limit: got %+v", algorithm, res)
+		}
+		if res, err := store.Take("other"+key, algorithm, 3, time.Minute); err != nil || !res.Allowed {
+			t.Errorf("algorithm %d, another key: got %+v %v", algorithm, res, err)
+		}
+	}
+}
@@ -0,0 +1,142 @@
+package server
+
+import (
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+type fakeClock struct{ t time.Time }
+
+func (c *fakeClock) now() time.Time { return c.t }
+
+func newFakeStore() (*MemoryRateLimitStore, *fakeClock) {
+	clock := &fakeClock{t: time.Unix(1000000, 0)}
+	store := NewMemoryRateLimitStore()
+	store.now, store.lastSweep = clock.now, clock.t
+	return store, clock
+}
+
+func TestTokenBucket(t *testing.T) {
+	store, clock := newFakeStore()
+	for i := 0; i < 3; i++ {
+		res, _ := store.Take("k", TokenBucket, 3, 3*time.Second)
+		if !res.Allowed || res.Remaining != 2-i {
+			t.Fatalf("request %d: got %+v", i, res)
+		}
+	}
+	res, _ := store.Take("k", TokenBucket, 3, 3*time.Second)
+	if res.Allowed || res.RetryAfter != time.Second || res.Reset != 3*time.Second {
+		t.Fatalf("over the burst: got %+v", res)
+	}
+	clock.t = clock.t.Add(time.Second)
+	if res, _ := store.Take("k", TokenBucket, 3, 3*time.Second); !res.Allowed || res.Remaining != 0 {
+		t.Fatalf("after a refill: got %+v", res)
+	}
+	if res, _ := store.Take("other", TokenBucket, 3, 3*time.Second); !res.Allowed {
+		t.Fatalf("another key: got %+v", res)
+	}
+}
+
+func TestSlidingWindow(t *testing.T) {
+	store, clock := newFakeStore()
+	window := 10 * time.Second
+	// start of a fixed window
+	clock.t = time.Unix(0, (clock.t.UnixNano()/int64(window)+1)*int64(window))
+	for i := 0; i < 4; i++ {
+		if res, _ := store.Take("k", SlidingWindow, 4, window); !res.Allowed {
+			t.Fatalf("request %d: got %+v", i, res)
+		}
+	}
+	res, _ := store.Take("k", SlidingWindow, 4, window)
+	if res.Allowed || res.Remaining != 0 || res.Reset != window || res.RetryAfter != window+window/4 {
+		t.Fatalf("over the limit: got %+v", res)
+	}
+
+	// in the next window the previous requests still weigh 3/4
+	clock.t = clock.t.Add(window + window/4)
+	if res, _ := store.Take("k", SlidingWindow, 4, window); !res.Allowed {
+		t.Fatalf("next window: got %+v", res)
+	}
+	res, _ = store.Take("k", SlidingWindow, 4, window)
+	if res.Allowed || res.RetryAfter != window/4 {
+		t.Fatalf("weighted previous window: got %+v", res)
+	}
+	clock.t = clock.t.Add(res.RetryAfter)
+	if res, _ := store.Take("k", SlidingWindow, 4, window); !res.Allowed {
+		t.Fatalf("after Retry-After: got %+v", res)
+	}
+
+	// two windows later everything is forgotten
+	clock.t = clock.t.Add(2 * window)
+	if res, _ := store.Take("k", SlidingWindow, 4, window); !res.Allowed || res.Remaining != 3 {
+		t.Fatalf("later: got %+v", res)
+	}
+}
+
+func TestMemoryStoreSweep(t *testing.T) {
+	store, clock := newFakeStore()
+	store.Take("a", TokenBucket, 1, time.Second)
+	store.Take("b", SlidingWindow, 1, time.Second)
+	clock.t = clock.t.Add(2 * memorySweepInterval)
+	store.Take("c", TokenBucket, 1, time.Second)
+	if len(store.buckets) != 1 || len(store.windows) != 0 {
+		t.Errorf("idle limits were not dropped: %v %v", store.buckets, store.windows)
+	}
+}
+
+func TestRateLimitMiddleware(t *testing.T) {
+	s := newTestServer(t)
+	s.ErrorHandler(429, func(ctx *Context, err HTTPError) {
+		ctx.JSON(err.Code, map[string]string{"error": err.Message})
+	})
+	limit := RateLimit(RateLimitConfig{Limit: 2, Window: time.Minute, KeyFunc: RateLimitByHeader("X-API-Key")})
+	s.Get("/api", func() string { return "ok" }, limit)
+
+	send := func(key string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/api", nil)
+		if key != "" {
+			req.Header.Set("X-API-Key", key)
+		}
+		w := httptest.NewRecorder()
+		s.ServeHTTP(w, req)
+		return w
+	}
+
+	for i := 0; i < 2; i++ {
+		w := send("alice")
+		if w.Code != 200 || w.Header().Get("RateLimit-Limit") != "2" || w.Header().Get("RateLimit-Remaining") != string('1'-rune(i)) ||
+			w.Header().Get("RateLimit-Policy") != "2;w=60" {
+			t.Fatalf("request %d: got %d %v", i, w.Code, w.Header())
+		}
+	}
+	w := send("alice")
+	if w.Code != 429 || w.Body.String() != `{"error":"Too Many Requests"}` || w.Header().Get("Retry-After") != "30" ||
+		w.Header().Get("RateLimit-Remaining") != "0" || w.Header().Get("RateLimit-Reset") != "60" {
+		t.Fatalf("over the limit: got %d %q %v", w.Code, w.Body.String(), w.Header())
+	}
+	if w := send("bob"); w.Code != 200 {
+		t.Errorf("another API key: got %d", w.Code)
+	}
+	if w := send(""); w.Code != 200 {
+		t.Errorf("by client IP: got %d", w.Code)
+	}
+}
+
+func TestRateLimitConfigValidation(t *testing.T) {
+	for _, config := range []RateLimitConfig{
+		{Limit: 0, Window: time.Second},
+		{Limit: -1, Window: time.Second},
+		{Limit: 10},
+		{Limit: 10, Window: -time.Second},
+	} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("%+v: expected a panic", config)
+				}
+			}()
+			RateLimit(config)
+		}()
+	}
+}
@@ -0,0 +1,52 @@
+package server
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"github.com/widaT/golib/logger"
+	"net/http"
+)
+
+// maxRequestIDLen bounds the length of the request IDs accepted from
+// clients.
+const maxRequestIDLen = 128
+
+// requestID returns the X-Request-ID of req when it is a sane value set
+// by a client or a proxy, and a new random ID otherwise.
+func requestID(req *http.Request) string {
+	if id := req.Header.Get(logger.RequestIDHeader); validRequestID(id) {
+		return id
+	}
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return ""
+	}
+	return hex.EncodeToString(b)
+}
+
+// validRequestID reports whether id is short and only made of printable
+// characters which cannot break a log line.
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLen {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if c := id[i]; c <= ' ' || c >= 0x7f || c == '"' || c == '\\' {
+			return false
+		}
+	}
+	return true
+}
+
+// RequestID returns the ID of the request, taken from its X-Request-ID
+// header or generated. It is sent back in the response headers and
+// carried by the request context, see logger.RequestIDFromContext.
+func (ctx *Context) RequestID() string {
+	return ctx.requestID
+}
+
+// Logger returns the server logger tagging its messages with the
+// request ID.
+func (ctx *Context) Logger() *logger.GxLogger {
+	return ctx.Server.Logger.WithRequestID(ctx.requestID)
+}
@@ -0,0 +1,58 @@
+package server
+
+import (
+	"github.com/widaT/golib/httplib"
+	"github.com/widaT/golib/logger"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRequestID(t *testing.T) {
+	s := newTestServer(t)
+	s.Get("/id", func(ctx *Context) string {
+		return ctx.RequestID() + "," + logger.RequestIDFromContext(ctx.Request.Context())
+	})
+
+	req := httptest.NewRequest("GET", "/id", nil)
+	req.Header.Set("X-Request-ID", "abc-123")
+	w := httptest.NewRecorder()
+	s.ServeHTTP(w, req)
+	if w.Body.String() != "abc-123,abc-123" || w.Header().Get("X-Request-ID") != "abc-123" {
+		t.Errorf("got body %q and header %q", w.Body.String(), w.Header().Get("X-Request-ID"))
+	}
+
+	for _, incoming := range []string{"", "bad id", strings.Repeat("x", maxRequestIDLen+1)} {
+		req := httptest.NewRequest("GET", "/id", nil)
+		req.Header.Set("X-Request-ID", incoming)
+		w := httptest.NewRecorder()
+		s.ServeHTTP(w, req)
+		id := w.Header().Get("X-Request-ID")
+		if len(id) != 32 || w.Body.String() != id+","+id {
+			t.Errorf("incoming %q: got id %q and body %q", incoming, id, w.Body.String())
+		}
+	}
+}
+
+func TestRequestIDForwarding(t *testing.T) {
+	downstream := newTestServer(t)
+	downstream.Get("/", func(ctx *Context) string { return ctx.RequestID() })
+	ts := httptest.NewServer(downstream)
+	defer ts.Close()
+
+	s := newTestServer(t)
+	s.Get("/proxy", func(ctx *Context) string {
+		body, err := httplib.Get(ts.URL).WithContext(ctx.Request.Context()).String()
+		if err != nil {
+			return err.Error()
+		}
+		return body
+	})
+	req := httptest.NewRequest("GET", "/proxy", nil)
+	req.Header.Set("X-Request-ID", "trace-42")
+	w := httptest.NewRecorder()
+	s.ServeHTTP(w, req)
+	if w.Body.String() != "trace-42" {
+		t.Errorf("downstream got request ID %q", w.Body.String())
+	}
+}
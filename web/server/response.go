@@ -0,0 +1,124 @@
+package server
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"net/http"
+	"reflect"
+	"regexp"
+	"strconv"
+)
+
+// JSON writes v encoded as JSON with the given status code.
+func (ctx *Context) JSON(status int, v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	ctx.SetHeader("Content-Type", "application/json; charset=utf-8", true)
+	ctx.WriteHeader(status)
+	_, err = ctx.ResponseWriter.Write(data)
+	return err
+}
+
+// XML writes v encoded as XML, preceded by the XML header, with the given
+// status code.
+func (ctx *Context) XML(status int, v interface{}) error {
+	data, err := xml.Marshal(v)
+	if err != nil {
+		return err
+	}
+	ctx.SetHeader("Content-Type", "application/xml; charset=utf-8", true)
+	ctx.WriteHeader(status)
+	if _, err = ctx.ResponseWriter.Write([]byte(xml.Header)); err != nil {
+		return err
+	}
+	_, err = ctx.ResponseWriter.Write(data)
+	return err
+}
+
+var jsonpCallbackRegex = regexp.MustCompile(`^[a-zA-Z_$][a-zA-Z0-9_$.]*$`)
+
+// JSONP writes v encoded as JSON wrapped in a call to callback. When
+// callback is empty the "callback" request parameter is used, and plain
+// JSON is written if there is none. Callbacks which are not a plain
+// javascript identifier path are rejected.
+func (ctx *Context) JSONP(status int, callback string, v interface{}) error {
+	if callback == "" {
+		ctx.parseForm()
+		callback = ctx.Request.Form.Get("callback")
+	}
+	if callback == "" {
+		return ctx.JSON(status, v)
+	}
+	if !jsonpCallbackRegex.MatchString(callback) {
+		return errors.New("invalid JSONP callback " + callback)
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	ctx.SetHeader("Content-Type", "application/javascript; charset=utf-8", true)
+	ctx.SetHeader("X-Content-Type-Options", "nosniff", true)
+	ctx.WriteHeader(status)
+	_, err = ctx.ResponseWriter.Write([]byte("/**/" + callback + "("))
+	if err == nil {
+		_, err = ctx.ResponseWriter.Write(data)
+	}
+	if err == nil {
+		_, err = ctx.ResponseWriter.Write([]byte(");"))
+	}
+	return err
+}
+
+// writeResult writes the values returned by a handler, its error aside,
+// see writeValue. A leading int is the status code, as in (int, T).
+func writeResult(ctx *Context, ret []reflect.Value) {
+	status := http.StatusOK
+	if len(ret) == 2 && ret[0].Kind() == reflect.Int {
+		status = int(ret[0].Int())
+		ret = ret[1:]
+	}
+	sval := ret[0]
+	if sval.Kind() == reflect.String {
+		writeBody(ctx, status, []byte(sval.String()))
+	} else if sval.Kind() == reflect.Slice && sval.Type().Elem().Kind() == reflect.Uint8 {
+		writeBody(ctx, status, sval.Bytes())
+	} else {
+		writeValue(ctx, status, sval.Interface())
+	}
+}
+
+// writeValue writes a value returned by a handler: a string or a []byte
+// as is, any other value encoded as JSON.
+func writeValue(ctx *Context, status int, v interface{}) {
+	var content []byte
+	switch v := v.(type) {
+	case string:
+		content = []byte(v)
+	case []byte:
+		content = v
+	default:
+		data, err := json.Marshal(v)
+		if err != nil {
+			ctx.HandleError(err)
+			return
+		}
+		ctx.SetHeader("Content-Type", "application/json; charset=utf-8", true)
+		content = data
+	}
+	writeBody(ctx, status, content)
+}
+
+// writeBody writes content with its Content-Length.
+func writeBody(ctx *Context, status int, content []byte) {
+	ctx.SetHeader("Content-Length", strconv.Itoa(len(content)), true)
+	if status != http.StatusOK {
+		ctx.WriteHeader(status)
+	}
+	_, werr := ctx.ResponseWriter.Write(content)
+	if werr != nil {
+		ctx.Logger().Error("Error during write: %v", werr)
+	}
+}
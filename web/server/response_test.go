@@ -0,0 +1,48 @@
+package server
+
+import (
+	"errors"
+	"testing"
+)
+
+type resultUser struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+}
+
+func TestHandlerResults(t *testing.T) {
+	s := newTestServer(t)
+	s.Get("/struct", func() resultUser { return resultUser{1, "ann"} })
+	s.Get("/ptr", func() (*resultUser, error) { return &resultUser{2, "bob"}, nil })
+	s.Get("/ptr-err", func() (*resultUser, error) { return nil, HTTPError{Code: 404, Message: "no user"} })
+	s.Get("/created", func() (int, resultUser) { return 201, resultUser{3, "cat"} })
+	s.Get("/created-err", func() (int, resultUser, error) { return 0, resultUser{}, errors.New("failed") })
+	s.Get("/accepted", func() (int, string) { return 202, "queued" })
+	s.Get("/map", func() map[string]int { return map[string]int{"n": 1} })
+	s.Get("/bytes", func() ([]byte, error) { return []byte("raw"), nil })
+	s.Get("/unencodable", func() interface{} { return make(chan int) })
+
+	tests := []struct {
+		path  string
+		code  int
+		body  string
+		ctype string
+	}{
+		{"/struct", 200, `{"id":1,"name":"ann"}`, "application/json; charset=utf-8"},
+		{"/ptr", 200, `{"id":2,"name":"bob"}`, "application/json; charset=utf-8"},
+		{"/ptr-err", 404, "no user", "text/html; charset=utf-8"},
+		{"/created", 201, `{"id":3,"name":"cat"}`, "application/json; charset=utf-8"},
+		{"/created-err", 500, "Internal Server Error", "text/html; charset=utf-8"},
+		{"/accepted", 202, "queued", "text/html; charset=utf-8"},
+		{"/map", 200, `{"n":1}`, "application/json; charset=utf-8"},
+		{"/bytes", 200, "raw", "text/html; charset=utf-8"},
+		{"/unencodable", 500, "Internal Server Error", "text/html; charset=utf-8"},
+	}
+	for _, test := range tests {
+		w := doRequest(s, "GET", test.path, "")
+		if w.Code != test.code || w.Body.String() != test.body || w.Header().Get("Content-Type") != test.ctype {
+			t.Errorf("%s: got %d %q %q, want %d %q %q", test.path, w.Code, w.Body.String(), w.Header().Get("Content-Type"),
+				test.code, test.body, test.ctype)
+		}
+	}
+}
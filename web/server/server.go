@@ -1,26 +1,27 @@
 package server
 
 import (
-	"bytes"
-	"compress/gzip"
+	"context"
 	"crypto/tls"
-	"fmt"
 	"github.com/widaT/golib/logger"
-	"log"
+	"github.com/widaT/golib/web/client"
+	"html/template"
+	"io"
 	"net"
 	"net/http"
 	"net/http/pprof"
+	"os"
+	"os/signal"
 	"path"
 	"reflect"
 	"regexp"
-	"runtime"
-	"strconv"
+	"sort"
 	"strings"
+	"sync"
+	"syscall"
 	"time"
 )
 
-const gzipMinLength = 20
-
 // ServerConfig is configuration for server objects.
 type ServerConfig struct {
 	StaticDir    string
@@ -30,17 +31,104 @@ type ServerConfig struct {
 	RecoverPanic bool
 	Profiler     bool
 	GZIP         bool
+	// CompressMinLength is the smallest body compressed when GZIP is set
+	// or by the Compress middleware, 256 bytes by default.
+	CompressMinLength int
+	// ShutdownTimeout bounds the time RunContext waits for in-flight
+	// requests once its context is done, their connections are then
+	// closed. Zero means no limit.
+	ShutdownTimeout time.Duration
+	// HandleSignals makes the server shut down gracefully on SIGINT
+	// and SIGTERM.
+	HandleSignals bool
+
+	// Timeouts and limits of the underlying http.Server, zero means none.
+	ReadTimeout       time.Duration
+	ReadHeaderTimeout time.Duration
+	WriteTimeout      time.Duration
+	IdleTimeout       time.Duration
+	MaxHeaderBytes    int
+	// MaxBodySize is the largest request body accepted, in bytes. Larger
+	// bodies are answered with 413 Request Entity Too Large.
+	MaxBodySize int64
+	// MaxMultipartMemory is the part of multipart bodies kept in memory,
+	// file parts beyond it are stored in temporary files. It defaults to
+	// 32MB.
+	MaxMultipartMemory int64
+	// MaxUploadFileSize and MaxUploadSize limit the size of each uploaded
+	// file and of all of them, zero means no limit. Multipart bodies are
+	// not read past MaxUploadSize, plus some room for the form fields.
+	MaxUploadFileSize int64
+	MaxUploadSize     int64
+	// AllowedUploadTypes lists the MIME types, like "image/png" or
+	// "image/*", FormFile accepts. Types are sniffed from the file content.
+	// Empty accepts any type.
+	AllowedUploadTypes []string
+
+	// AccessLogFormat is the format of the access log: "common" or
+	// "combined" for the Common and Combined Log Formats, "json" for JSON
+	// lines, or empty for the default format, which includes the params.
+	AccessLogFormat string
+	// AccessLogRedact lists the params whose values are masked in the
+	// access log, on top of common credentials like password and token.
+	AccessLogRedact []string
+
+	// TLSReloadInterval is how often RunTLSFiles checks the certificate
+	// files for changes. It defaults to 10 seconds.
+	TLSReloadInterval time.Duration
+	// TLSClientCAFile enables client certificate authentication with the
+	// CAs of the PEM file. TLSClientAuth defaults to RequireAndVerifyClientCert.
+	TLSClientCAFile string
+	TLSClientAuth   tls.ClientAuthType
+
+	// WebSocketOrigins lists the origins allowed to open WebSocket
+	// connections, like "https://*.example.com". Empty only allows the
+	// origin of the server itself.
+	WebSocketOrigins []string
+	// WebSocketReadLimit is the largest message accepted, 1MB by default.
+	WebSocketReadLimit int64
+	// WebSocketPingInterval is how often peers are pinged, 30 seconds by
+	// default. Reading from a peer not answering within two intervals
+	// fails, see Server.WebSocket.
+	WebSocketPingInterval time.Duration
+
+	// TrustedProxies are the CIDR networks or IPs of the proxies whose
+	// forwarding headers are trusted to find the client address. Nil
+	// trusts loopback addresses only, see client.DefaultResolver; list
+	// the proxies of private networks explicitly.
+	TrustedProxies []string
+
+	// TemplateReload parses the templates again when their files change,
+	// for development.
+	TemplateReload bool
 }
 
 // Server represents a web.go server.
 type Server struct {
 	Config  *ServerConfig
 	tree    *Tree
+	routes  map[string]methodRoutes
 	filters []filterRoute
 	Logger  *logger.GxLogger
 	Env     map[string]interface{}
-	//save the listener so it can be closed
-	l net.Listener
+	// AccessLog receives the access log lines. When nil they go to Logger.
+	AccessLog io.Writer
+	// middlewares wrap every request, including unmatched ones
+	middlewares []Middleware
+	// chained is dispatch wrapped in middlewares, built by Use
+	chained HandlerFunc
+	statics []*StaticMount
+	// errorHandlers by status code, see ErrorHandler
+	errorHandlers map[int]ErrorHandlerFunc
+	cors          *CORSConfig
+	templates     *templateSet
+	templateFuncs template.FuncMap
+	//save the http server so it can be shut down
+	mu  sync.Mutex
+	srv *http.Server
+
+	resolverOnce sync.Once
+	resolver     *client.IPResolver
 }
 
 func NewServer() *Server {
@@ -48,6 +136,7 @@ func NewServer() *Server {
 		Config: Config,
 		Logger: defaultLogger,
 		tree:   NewTree(),
+		routes: map[string]methodRoutes{},
 		Env:    map[string]interface{}{},
 	}
 }
@@ -64,38 +153,165 @@ func (s *Server) initServer() {
 
 type route struct {
 	method      string
-	handler     reflect.Value
-	httpHandler http.Handler
+	handler     HandlerFunc
+	group       *RouteGroup
+	middlewares []Middleware
+	// chained is handler wrapped in the route and group middlewares
+	chained HandlerFunc
+	// raw handlers are http.Handlers, reading the request body themselves
+	raw bool
 }
 
+// FilerFun is a filter run before the handler; returning false stops the
+// request. Middlewares supersede filters, see FilterMiddleware.
 type FilerFun func(*Context) bool
 
 type filterRoute struct {
+	prefix  string
 	r       string
 	cr      *regexp.Regexp
 	handler FilerFun
 }
 
-func (s *Server) addRoute(r string, handler interface{}) {
+// match reports whether the filter applies to path. Filters registered on
+// a group only see paths under the group prefix, with the prefix stripped.
+func (f *filterRoute) match(path string) bool {
+	if f.prefix != "" && f.prefix != "/" {
+		if !hasPathPrefix(path, f.prefix) {
+			return false
+		}
+		path = path[len(f.prefix):]
+	}
+	return f.cr == nil || f.cr.MatchString(path)
+}
+
+// methodRoutes holds the routes registered on one path, keyed by HTTP
+// method. Routes bound to every verb are stored under anyMethod.
+type methodRoutes map[string]*route
+
+const anyMethod = "*"
+
+// lookup returns the route serving method, falling back to the GET route
+// for HEAD requests and to the any-method route otherwise.
+func (m methodRoutes) lookup(method string) *route {
+	if r, ok := m[method]; ok {
+		return r
+	}
+	if method == "HEAD" {
+		if r, ok := m["GET"]; ok {
+			return r
+		}
+	}
+	return m[anyMethod]
+}
+
+// allow returns the value of the Allow header for the path.
+func (m methodRoutes) allow() string {
+	set := map[string]bool{"OPTIONS": true}
+	for method := range m {
+		if method == anyMethod {
+			continue
+		}
+		set[method] = true
+		if method == "GET" {
+			set["HEAD"] = true
+		}
+	}
+	methods := make([]string, 0, len(set))
+	for method := range set {
+		methods = append(methods, method)
+	}
+	sort.Strings(methods)
+	return strings.Join(methods, ", ")
+}
+
+func (s *Server) addRoute(r string, method string, handler interface{}, middlewares []Middleware) *route {
+	rt := &route{method: method, handler: compileHandler(handler), middlewares: middlewares}
 	switch handler.(type) {
-	case http.Handler:
-		s.tree.AddRouter(r, route{httpHandler: handler.(http.Handler)})
-	case reflect.Value:
-		fv := handler.(reflect.Value)
-		s.tree.AddRouter(r, route{handler: fv})
-	default:
-		fv := reflect.ValueOf(handler)
-		s.tree.AddRouter(r, route{handler: fv})
+	case http.Handler, func(http.ResponseWriter, *http.Request):
+		rt.raw = true
+	}
+	rt.build()
+
+	if s.tree == nil {
+		s.tree = NewTree()
 	}
+	if s.routes == nil {
+		s.routes = map[string]methodRoutes{}
+	}
+	key := "/" + strings.Join(splitPath(r), "/")
+	routes, ok := s.routes[key]
+	if !ok {
+		routes = methodRoutes{}
+		s.routes[key] = routes
+		s.tree.AddRouter(r, routes)
+	}
+	routes[method] = rt
+	return rt
 }
 
-func (s *Server) addFilter(r string, fn FilerFun) {
-	cr, err := regexp.Compile(r)
-	if err != nil {
-		s.Logger.Printf("Error in filter regex %q\n", r)
-		return
+// AddRoute binds handler to route for every HTTP method.
+func (s *Server) AddRoute(route string, handler interface{}, middlewares ...Middleware) {
+	s.addRoute(route, anyMethod, handler, middlewares)
+}
+
+// Match binds handler to route for the given HTTP method.
+func (s *Server) Match(method string, route string, handler interface{}, middlewares ...Middleware) {
+	s.addRoute(route, strings.ToUpper(method), handler, middlewares)
+}
+
+// Get adds a handler for the 'GET' http method for server s.
+func (s *Server) Get(route string, handler interface{}, middlewares ...Middleware) {
+	s.addRoute(route, "GET", handler, middlewares)
+}
+
+// Post adds a handler for the 'POST' http method for server s.
+func (s *Server) Post(route string, handler interface{}, middlewares ...Middleware) {
+	s.addRoute(route, "POST", handler, middlewares)
+}
+
+// Put adds a handler for the 'PUT' http method for server s.
+func (s *Server) Put(route string, handler interface{}, middlewares ...Middleware) {
+	s.addRoute(route, "PUT", handler, middlewares)
+}
+
+// Delete adds a handler for the 'DELETE' http method for server s.
+func (s *Server) Delete(route string, handler interface{}, middlewares ...Middleware) {
+	s.addRoute(route, "DELETE", handler, middlewares)
+}
+
+// Patch adds a handler for the 'PATCH' http method for server s.
+func (s *Server) Patch(route string, handler interface{}, middlewares ...Middleware) {
+	s.addRoute(route, "PATCH", handler, middlewares)
+}
+
+// Options adds a handler for the 'OPTIONS' http method for server s.
+func (s *Server) Options(route string, handler interface{}, middlewares ...Middleware) {
+	s.addRoute(route, "OPTIONS", handler, middlewares)
+}
+
+// Any adds a handler for every http method for server s.
+func (s *Server) Any(route string, handler interface{}, middlewares ...Middleware) {
+	s.addRoute(route, anyMethod, handler, middlewares)
+}
+
+func (s *Server) addFilter(prefix string, r string, fn FilerFun) {
+	var cr *regexp.Regexp
+	if r != "" {
+		var err error
+		cr, err = regexp.Compile(r)
+		if err != nil {
+			s.Logger.Printf("Error in filter regex %q\n", r)
+			return
+		}
 	}
-	s.filters = append(s.filters, filterRoute{r: r, cr: cr, handler: fn})
+	s.filters = append(s.filters, filterRoute{prefix: prefix, r: r, cr: cr, handler: fn})
+}
+
+// AddFilter runs fn before the handler of every request whose path matches
+// the regex r. A filter returning false stops the request.
+func (s *Server) AddFilter(r string, fn FilerFun) {
+	s.addFilter("", r, fn)
 }
 
 // ServeHTTP is the interface method for Go's http server package
@@ -105,86 +321,147 @@ func (s *Server) ServeHTTP(c http.ResponseWriter, req *http.Request) {
 
 // Process invokes the routing system for server s
 func (s *Server) Process(c http.ResponseWriter, req *http.Request) {
-	//println(req.PostFormValue("gid"))
-	route := s.routeHandler(req, c)
-	if route != nil {
-		route.httpHandler.ServeHTTP(c, req)
-	}
+	s.routeHandler(req, c)
 }
 
 //Adds a custom handler. Only for webserver mode. Will have no effect when running as FCGI or SCGI.
-func (s *Server) Handler(route string, httpHandler http.Handler) {
-	s.addRoute(route, httpHandler)
+func (s *Server) Handler(route string, httpHandler http.Handler, middlewares ...Middleware) {
+	s.addRoute(route, anyMethod, httpHandler, middlewares)
 }
 
-// Run starts the web application and serves HTTP requests for s
-func (s *Server) Run(addr string) {
-	s.initServer()
-
-	mux := http.NewServeMux()
-	if s.Config.Profiler {
-		mux.Handle("/debug/pprof/cmdline", http.HandlerFunc(pprof.Cmdline))
-		mux.Handle("/debug/pprof/profile", http.HandlerFunc(pprof.Profile))
-		mux.Handle("/debug/pprof/heap", pprof.Handler("heap"))
-		mux.Handle("/debug/pprof/symbol", http.HandlerFunc(pprof.Symbol))
-	}
-	mux.Handle("/", s)
+// Run starts the web application and serves HTTP requests for s.
+// It returns when the server is closed or shut down, or fails to listen.
+func (s *Server) Run(addr string) error {
+	return s.RunContext(context.Background(), addr)
+}
 
+// RunContext serves HTTP requests for s until ctx is done, then shuts the
+// server down gracefully, waiting at most Config.ShutdownTimeout for the
+// in-flight requests to complete before closing their connections.
+func (s *Server) RunContext(ctx context.Context, addr string) error {
+	s.initServer()
 	s.Logger.Printf("web.go serving %s", addr)
 
 	l, err := net.Listen("tcp", addr)
 	if err != nil {
-		log.Fatal("ListenAndServe:", err)
+		s.Logger.Error("ListenAndServe: %v", err)
+		return err
 	}
-	s.l = l
-	err = http.Serve(s.l, mux)
-	s.l.Close()
+	return s.serve(ctx, l)
 }
 
 // RunTLS starts the web application and serves HTTPS requests for s.
 func (s *Server) RunTLS(addr string, config *tls.Config) error {
 	s.initServer()
-	mux := http.NewServeMux()
-	mux.Handle("/", s)
 	l, err := tls.Listen("tcp", addr, config)
 	if err != nil {
-		log.Fatal("Listen:", err)
+		s.Logger.Error("Listen: %v", err)
 		return err
 	}
+	return s.serve(context.Background(), l)
+}
 
-	s.l = l
-	return http.Serve(s.l, mux)
+// handler returns the root handler of s, with the profiler when enabled.
+func (s *Server) handler() http.Handler {
+	mux := http.NewServeMux()
+	if s.Config.Profiler {
+		mux.Handle("/debug/pprof/cmdline", http.HandlerFunc(pprof.Cmdline))
+		mux.Handle("/debug/pprof/profile", http.HandlerFunc(pprof.Profile))
+		mux.Handle("/debug/pprof/heap", pprof.Handler("heap"))
+		mux.Handle("/debug/pprof/symbol", http.HandlerFunc(pprof.Symbol))
+	}
+	mux.Handle("/", s)
+	return mux
 }
 
-// Close stops server s.
-func (s *Server) Close() {
-	if s.l != nil {
-		s.l.Close()
+// serve serves requests accepted on l until ctx is done or s is closed.
+func (s *Server) serve(ctx context.Context, l net.Listener) error {
+	srv := &http.Server{
+		Handler:           s.handler(),
+		ReadTimeout:       s.Config.ReadTimeout,
+		ReadHeaderTimeout: s.Config.ReadHeaderTimeout,
+		WriteTimeout:      s.Config.WriteTimeout,
+		IdleTimeout:       s.Config.IdleTimeout,
+		MaxHeaderBytes:    s.Config.MaxHeaderBytes,
+	}
+	s.mu.Lock()
+	s.srv = srv
+	s.mu.Unlock()
+
+	if s.Config.HandleSignals {
+		var stop func()
+		ctx, stop = notifyContext(ctx)
+		defer stop()
+	}
+
+	errc := make(chan error, 1)
+	go func() {
+		errc <- srv.Serve(l)
+	}()
+
+	select {
+	case err := <-errc:
+		if err == http.ErrServerClosed {
+			return nil
+		}
+		return err
+	case <-ctx.Done():
+		sctx := context.Background()
+		if s.Config.ShutdownTimeout > 0 {
+			var cancel context.CancelFunc
+			sctx, cancel = context.WithTimeout(sctx, s.Config.ShutdownTimeout)
+			defer cancel()
+		}
+		err := s.Shutdown(sctx)
+		if err == context.DeadlineExceeded {
+			// the deadline ends the server, closing the connections left
+			s.Close()
+		}
+		<-errc
+		return err
 	}
 }
 
-// safelyCall invokes `function` in recover block
-func (s *Server) safelyCall(function reflect.Value, args []reflect.Value) (resp []reflect.Value, e interface{}) {
-	defer func() {
-		if err := recover(); err != nil {
-			if !s.Config.RecoverPanic {
-				// go back to panic
-				panic(err)
-			} else {
-				e = err
-				resp = nil
-				s.Logger.Error("Handler crashed with error", err)
-				for i := 1; ; i += 1 {
-					_, file, line, ok := runtime.Caller(i)
-					if !ok {
-						break
-					}
-					s.Logger.Error(file, line)
-				}
-			}
+// notifyContext returns a copy of ctx which is done on SIGINT or SIGTERM.
+// Calling stop releases the signal handler.
+func notifyContext(ctx context.Context) (context.Context, func()) {
+	ctx, cancel := context.WithCancel(ctx)
+	c := make(chan os.Signal, 1)
+	signal.Notify(c, syscall.SIGINT, syscall.SIGTERM)
+	go func() {
+		select {
+		case <-c:
+			cancel()
+		case <-ctx.Done():
 		}
 	}()
-	return function.Call(args), nil
+	return ctx, func() {
+		signal.Stop(c)
+		cancel()
+	}
+}
+
+// Shutdown gracefully stops server s: it stops accepting connections and
+// waits for the active requests to complete or for ctx to be done.
+func (s *Server) Shutdown(ctx context.Context) error {
+	s.mu.Lock()
+	srv := s.srv
+	s.mu.Unlock()
+	if srv == nil {
+		return nil
+	}
+	s.Logger.Printf("web.go shutting down")
+	return srv.Shutdown(ctx)
+}
+
+// Close stops server s immediately, closing the active connections.
+func (s *Server) Close() {
+	s.mu.Lock()
+	srv := s.srv
+	s.mu.Unlock()
+	if srv != nil {
+		srv.Close()
+	}
 }
 
 // requiresContext determines whether 'handlerType' contains
@@ -215,6 +492,11 @@ func requiresContext(handlerType reflect.Type) bool {
 // 2) The 'static' directory in the parent directory of the executable.
 // 3) The 'static' directory in the current working directory
 func (s *Server) tryServingFile(name string, req *http.Request, w http.ResponseWriter) bool {
+	//clean the name so it cannot escape the static directories
+	if containsDotDot(name) {
+		return false
+	}
+	name = path.Clean("/" + name)
 	//try to serve a static file
 	if s.Config.StaticDir != "" {
 		staticFile := path.Join(s.Config.StaticDir, name)
@@ -234,158 +516,162 @@ func (s *Server) tryServingFile(name string, req *http.Request, w http.ResponseW
 	return false
 }
 
-func (s *Server) logRequest(ctx Context, sTime time.Time) {
-	//log the request
-	var logEntry bytes.Buffer
-	req := ctx.Request
+// the main route handler in web.go
+// Tries to handle the given request.
+// Finds the route matching the request, and runs the server middlewares
+// around the filters and the callback associated with it.
+func (s *Server) routeHandler(req *http.Request, w http.ResponseWriter) {
 	requestPath := req.URL.Path
+	tm := time.Now().UTC()
 
-	duration := time.Now().Sub(sTime)
-	var client string
+	id := requestID(req)
+	req = req.WithContext(logger.NewRequestIDContext(req.Context(), id))
+	w.Header().Set(logger.RequestIDHeader, id)
 
-	// We suppose RemoteAddr is of the form Ip:Port as specified in the Request
-	// documentation at http://golang.org/pkg/net/http/#Request
-	pos := strings.LastIndex(req.RemoteAddr, ":")
-	if pos > 0 {
-		client = req.RemoteAddr[0:pos]
-	} else {
-		client = req.RemoteAddr
-	}
-	//考虑代理lbs代理ip
-	if ctx.Request.Header.Get("X-Forwarded-For") != "" {
-		client = ctx.Request.Header.Get("X-Forwarded-For")
-	}
-	fmt.Fprintf(&logEntry, "%s - %s %s - %v", client, req.Method, requestPath, duration)
-
-	//处理参数超过500的情况，防止日志过大
-	if len(ctx.Params) > 0 {
-		paramsCopy := make(map[string]string)
-		for key, param := range ctx.Params {
-			if len(param) > 1000 {
-				paramsCopy[key] = "len longger than 1000"
-			} else {
-				paramsCopy[key] = param
-			}
+	lw := &logWriter{ResponseWriter: w}
+	ctx := &Context{Request: req, Params: map[string]string{}, Server: s, ResponseWriter: lw, requestID: id}
+	defer s.logRequest(ctx, tm, lw)
+	// net/http only cleans up the form of the request it passed us, not
+	// the one of our copy, so the temporary files of uploads are ours
+	defer func() {
+		if ctx.Request.MultipartForm != nil {
+			ctx.Request.MultipartForm.RemoveAll()
 		}
-		fmt.Fprintf(&logEntry, " - Params: %v", paramsCopy)
-	}
-	ctx.Server.Logger.Print(logEntry.String())
-}
-
-// the main route handler in web.go
-// Tries to handle the given request.
-// Finds the route matching the request, and execute the callback associated
-// with it.  In case of custom http handlers, this function returns an "unused"
-// route. The caller is then responsible for calling the httpHandler associated
-// with the returned route.
-func (s *Server) routeHandler(req *http.Request, w http.ResponseWriter) (unused *route) {
-	requestPath := req.URL.Path
+	}()
 
-	ctx := Context{req, map[string]string{}, s, w}
+	if s.Config.GZIP {
+		if cw := newCompressWriter(lw, req, s.Config); cw != nil {
+			defer cw.Close()
+			ctx.ResponseWriter = cw
+		}
+	}
 
 	//set some default headers
 	ctx.SetHeader("Server", "gxrsgo", true)
-	tm := time.Now().UTC()
 
-	//ignore errors from ParseForm because it's usually harmless.
-	req.ParseForm()
-	if len(req.Form) > 0 {
-		for k, v := range req.Form {
-			ctx.Params[k] = v[0]
+	var body *limitedBody
+	if s.Config.MaxBodySize > 0 && req.Body != nil {
+		if req.ContentLength > s.Config.MaxBodySize {
+			s.handleError(ctx, HTTPError{Code: http.StatusRequestEntityTooLarge, Message: "Request Entity Too Large"})
+			return
 		}
+		body = &limitedBody{ReadCloser: http.MaxBytesReader(w, req.Body, s.Config.MaxBodySize), limit: s.Config.MaxBodySize}
+		req.Body = body
+		ctx.body = body
 	}
 
-	req.ParseMultipartForm(32 << 20)
-	if len(req.PostForm) > 0 {
-		for k, v := range req.PostForm {
-			ctx.Params[k] = v[0]
-		}
+	//ignore errors from ParseForm because it's usually harmless.
+	//multipart bodies are left to dispatch, once the route is known.
+	req.ParseForm()
+	for k, v := range req.Form {
+		ctx.Params[k] = v[0]
 	}
 
-	defer s.logRequest(ctx, tm)
+	if body != nil && body.tooLarge {
+		s.handleError(ctx, HTTPError{Code: http.StatusRequestEntityTooLarge, Message: "Request Entity Too Large"})
+		return
+	}
 
 	ctx.SetHeader("Date", webTime(tm), true)
 
-	if req.Method == "GET" || req.Method == "HEAD" {
-		if s.tryServingFile(requestPath, req, w) {
-			return
-		}
-	}
-
 	//Set the default content-type
 	ctx.SetHeader("Content-Type", "text/html; charset=utf-8", true)
 
+	ret, pathParams := s.tree.MatchParams(requestPath)
+	if ret != nil {
+		ctx.routes = ret.(methodRoutes)
+	}
+	ctx.pathParams = pathParams
+
+	if s.cors != nil && s.cors.handleCORS(ctx) {
+		return
+	}
+
+	defer s.recoverPanic(ctx)
+	if s.chained != nil {
+		s.chained(ctx)
+		return
+	}
+	s.dispatch(ctx)
+}
+
+// dispatch runs the filters, then the matched route wrapped in its group
+// and route middlewares. It answers 404 and 405 itself.
+func (s *Server) dispatch(ctx *Context) {
+	req := ctx.Request
+	requestPath := req.URL.Path
+
+	var route *route
+	if ctx.routes != nil {
+		route = ctx.routes.lookup(req.Method)
+	}
+	// the Params hold the multipart form for the filters and handlers,
+	// except for the http.Handlers reading the body themselves
+	if route != nil && !route.raw {
+		ctx.parseForm()
+	}
+
 	//do the filters
 	for i := 0; i < len(s.filters); i++ {
-		filter_route := s.filters[i]
-		cr := filter_route.cr
-		if !cr.MatchString(requestPath) {
+		filter_route := &s.filters[i]
+		if !filter_route.match(requestPath) {
 			continue
 		}
 		fn := filter_route.handler
-		if !fn(&ctx) {
+		if !fn(ctx) {
 			return
 		}
 	}
 
-	if ret := s.tree.Match(requestPath); ret != nil {
-		route := ret.(route)
-		if route.httpHandler != nil {
-			unused = &route
-			// We can not handle custom http handlers here, give back to the caller.
-			return
-		}
-		var args []reflect.Value
-		handlerType := route.handler.Type()
-		if requiresContext(handlerType) {
-			args = append(args, reflect.ValueOf(&ctx))
-		}
-
-		ret, err := s.safelyCall(route.handler, args)
-		if err != nil {
-			ctx.Abort(500, "Server Error")
-		}
-
-		if len(ret) == 0 {
+	if ctx.routes != nil {
+		if route == nil {
+			ctx.SetHeader("Allow", ctx.routes.allow(), true)
+			if req.Method == "OPTIONS" {
+				ctx.SetHeader("Content-Length", "0", true)
+				ctx.WriteHeader(http.StatusNoContent)
+				return
+			}
+			s.handleError(ctx, HTTPError{Code: http.StatusMethodNotAllowed, Message: "Method Not Allowed"})
 			return
 		}
-
-		sval := ret[0]
-		var content []byte
-
-		if sval.Kind() == reflect.String {
-			content = []byte(sval.String())
-		} else if sval.Kind() == reflect.Slice && sval.Type().Elem().Kind() == reflect.Uint8 {
-			content = sval.Interface().([]byte)
-		}
-
-		if s.Config.GZIP && strings.Contains(ctx.Request.Header.Get("Accept-Encoding"), "gzip") && len(content) > gzipMinLength {
-			ctx.SetHeader("Content-Encoding", "gzip", true)
-			ctx.SetHeader("Vary", "Accept-Encoding", true)
-			ctx.Header().Del("Content-Length")
-			ctx.SetHeader("Transfer-Encoding", "chunked", true)
-			gz := gzip.NewWriter(ctx.ResponseWriter)
-			defer gz.Close()
-			_, err = gz.Write(content)
-		} else {
-			ctx.SetHeader("Content-Length", strconv.Itoa(len(content)), true)
-			_, err = ctx.ResponseWriter.Write(content)
-		}
-		if err != nil {
-			ctx.Server.Logger.Error("Error during write: ", err)
-		}
+		route.chained(ctx)
 		return
 	}
-	// try serving index.html or index.htm
+	// no route matched, try the static mounts then the static dirs,
+	// serving index.html or index.htm for directories
 	if req.Method == "GET" || req.Method == "HEAD" {
-		if s.tryServingFile(path.Join(requestPath, "index.html"), req, w) {
+		//let the file server detect the content type
+		ctx.Header().Del("Content-Type")
+		if s.serveStatic(ctx) {
+			return
+		} else if s.tryServingFile(requestPath, req, ctx.ResponseWriter) {
+			return
+		} else if s.tryServingFile(path.Join(requestPath, "index.html"), req, ctx.ResponseWriter) {
 			return
-		} else if s.tryServingFile(path.Join(requestPath, "index.htm"), req, w) {
+		} else if s.tryServingFile(path.Join(requestPath, "index.htm"), req, ctx.ResponseWriter) {
 			return
 		}
+		ctx.SetHeader("Content-Type", "text/html; charset=utf-8", true)
+	}
+	s.handleError(ctx, HTTPError{Code: http.StatusNotFound, Message: "Page not found"})
+}
+
+// limitedBody wraps a request body capped by http.MaxBytesReader and
+// records whether the cap was exceeded.
+type limitedBody struct {
+	io.ReadCloser
+	limit    int64
+	read     int64
+	tooLarge bool
+}
+
+func (b *limitedBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.read += int64(n)
+	if err != nil && err != io.EOF && b.read >= b.limit {
+		b.tooLarge = true
 	}
-	ctx.Abort(404, "Page not found")
-	return
+	return n, err
 }
 
 // SetLogger sets the logger for server s
@@ -0,0 +1,289 @@
+package server
+
+import (
+	"context"
+	"github.com/widaT/golib/logger"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testDir holds the access log and static files of test servers.
+var testDir string
+
+func TestMain(m *testing.M) {
+	var err error
+	testDir, err = ioutil.TempDir("", "server")
+	if err != nil {
+		panic(err)
+	}
+	code := m.Run()
+	os.RemoveAll(testDir)
+	os.Exit(code)
+}
+
+func newTestServer(t *testing.T) *Server {
+	s := NewServer()
+	s.Config = &ServerConfig{RecoverPanic: true, StaticDir: testDir}
+	s.Logger = logger.NewLogger(`{"filename":"` + filepath.ToSlash(filepath.Join(testDir, "access.log")) + `"}`)
+	return s
+}
+
+func doRequest(s *Server, method string, path string, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	if body != "" {
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	}
+	w := httptest.NewRecorder()
+	s.ServeHTTP(w, req)
+	return w
+}
+
+func TestMethodRouting(t *testing.T) {
+	s := newTestServer(t)
+	s.Get("/users/:id", func(ctx *Context) string { return "get " + ctx.PathParam("id") })
+	s.Post("/users/:id", func(ctx *Context) string { return "post " + ctx.PathParam("id") })
+	s.Any("/any", func() string { return "any" })
+
+	tests := []struct {
+		method string
+		path   string
+		status int
+		body   string
+		allow  string
+	}{
+		{"GET", "/users/1", 200, "get 1", ""},
+		{"POST", "/users/2", 200, "post 2", ""},
+		{"HEAD", "/users/3", 200, "get 3", ""},
+		{"DELETE", "/users/4", 405, "Method Not Allowed", "GET, HEAD, OPTIONS, POST"},
+		{"OPTIONS", "/users/5", 204, "", "GET, HEAD, OPTIONS, POST"},
+		{"PATCH", "/any", 200, "any", ""},
+		{"GET", "/missing", 404, "Page not found", ""},
+	}
+	for _, test := range tests {
+		w := doRequest(s, test.method, test.path, "")
+		if w.Code != test.status {
+			t.Errorf("%s %s: expected status %d got %d", test.method, test.path, test.status, w.Code)
+		}
+		if w.Body.String() != test.body {
+			t.Errorf("%s %s: expected body %q got %q", test.method, test.path, test.body, w.Body.String())
+		}
+		if allow := w.Header().Get("Allow"); allow != test.allow {
+			t.Errorf("%s %s: expected Allow %q got %q", test.method, test.path, test.allow, allow)
+		}
+	}
+}
+
+func TestMethodRoutingParamNames(t *testing.T) {
+	s := newTestServer(t)
+	s.Get("/users/:id", func() string { return "get" })
+	defer func() {
+		if recover() == nil {
+			t.Error("expected a panic registering /users/:uid next to /users/:id")
+		}
+	}()
+	s.Post("/users/:uid", func() string { return "post" })
+}
+
+func TestMethodRoutingHandler(t *testing.T) {
+	s := newTestServer(t)
+	s.Match("put", "/raw", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("raw"))
+	}))
+	if w := doRequest(s, "PUT", "/raw", ""); w.Body.String() != "raw" {
+		t.Errorf("expected raw got %q", w.Body.String())
+	}
+	if w := doRequest(s, "GET", "/raw", ""); w.Code != 405 {
+		t.Errorf("expected 405 got %d", w.Code)
+	}
+}
+
+func TestGroup(t *testing.T) {
+	s := newTestServer(t)
+	var trace []string
+	api := s.Group("/api/v1", func(ctx *Context) bool {
+		trace = append(trace, "api")
+		return ctx.Request.Header.Get("X-Token") != "bad"
+	})
+	api.Get("/users/:id", func(ctx *Context) string { return "user " + ctx.PathParam("id") })
+	admin := api.Group("admin", func(ctx *Context) bool {
+		trace = append(trace, "admin")
+		return true
+	})
+	admin.AddFilter("^/secret", func(ctx *Context) bool {
+		ctx.Abort(403, "forbidden")
+		return false
+	})
+	admin.AddRoute("/stats", func() string { return "stats" })
+	admin.AddRoute("/secret", func() string { return "secret" })
+	s.Get("/api/v10", func() string { return "v10" })
+
+	tests := []struct {
+		path  string
+		body  string
+		trace string
+	}{
+		{"/api/v1/users/7", "user 7", "api"},
+		{"/api/v1/admin/stats", "stats", "api,admin"},
+		{"/api/v1/admin/secret", "forbidden", "api,admin"},
+		{"/api/v10", "v10", ""},
+	}
+	for _, test := range tests {
+		trace = nil
+		w := doRequest(s, "GET", test.path, "")
+		if w.Body.String() != test.body {
+			t.Errorf("%s: expected body %q got %q", test.path, test.body, w.Body.String())
+		}
+		if got := strings.Join(trace, ","); got != test.trace {
+			t.Errorf("%s: expected filters %q got %q", test.path, test.trace, got)
+		}
+	}
+}
+
+func TestRunContextGracefulShutdown(t *testing.T) {
+	s := newTestServer(t)
+	s.Config.ShutdownTimeout = 5 * time.Second
+	started := make(chan bool)
+	s.Get("/slow", func() string {
+		close(started)
+		time.Sleep(200 * time.Millisecond)
+		return "done"
+	})
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := l.Addr().String()
+	l.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	runErr := make(chan error, 1)
+	go func() { runErr <- s.RunContext(ctx, addr) }()
+
+	for i := 0; i < 50; i++ {
+		var c net.Conn
+		if c, err = net.Dial("tcp", addr); err == nil {
+			c.Close()
+			break
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	body := make(chan string, 1)
+	go func() {
+		client := &http.Client{Transport: &http.Transport{DisableKeepAlives: true}}
+		resp, err := client.Get("http://" + addr + "/slow")
+		if err != nil {
+			body <- err.Error()
+			return
+		}
+		defer resp.Body.Close()
+		b, _ := ioutil.ReadAll(resp.Body)
+		body <- string(b)
+	}()
+	<-started
+	cancel()
+
+	if got := <-body; got != "done" {
+		t.Errorf("expected in-flight request to complete, got %q", got)
+	}
+	if err := <-runErr; err != nil {
+		t.Errorf("expected nil error got %v", err)
+	}
+}
+
+func TestRunContextShutdownTimeout(t *testing.T) {
+	s := newTestServer(t)
+	s.Config.ShutdownTimeout = 100 * time.Millisecond
+	started := make(chan bool)
+	release := make(chan bool)
+	defer close(release)
+	s.Get("/stuck", func() string {
+		close(started)
+		<-release
+		return "done"
+	})
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := l.Addr().String()
+	l.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	runErr := make(chan error, 1)
+	go func() { runErr <- s.RunContext(ctx, addr) }()
+	for i := 0; i < 50; i++ {
+		var c net.Conn
+		if c, err = net.Dial("tcp", addr); err == nil {
+			c.Close()
+			break
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	reqErr := make(chan error, 1)
+	go func() {
+		resp, err := http.Get("http://" + addr + "/stuck")
+		if err == nil {
+			resp.Body.Close()
+		}
+		reqErr <- err
+	}()
+	<-started
+	cancel()
+
+	select {
+	case err := <-runErr:
+		if err != context.DeadlineExceeded {
+			t.Errorf("expected the deadline error got %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("RunContext did not return once the shutdown timed out")
+	}
+	select {
+	case err := <-reqErr:
+		if err == nil {
+			t.Error("expected the connection of the stuck request to be closed")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("the connection of the stuck request was left open")
+	}
+}
+
+func TestMaxBodySize(t *testing.T) {
+	s := newTestServer(t)
+	s.Config.MaxBodySize = 16
+	s.Post("/form", func(ctx *Context) string { return ctx.Params["a"] })
+
+	if w := doRequest(s, "POST", "/form", "a=small"); w.Code != 200 || w.Body.String() != "small" {
+		t.Errorf("expected 200 small got %d %q", w.Code, w.Body.String())
+	}
+	if w := doRequest(s, "POST", "/form", "a="+strings.Repeat("x", 32)); w.Code != 413 {
+		t.Errorf("expected 413 got %d", w.Code)
+	}
+
+	// without a Content-Length the limit is enforced while reading
+	req := httptest.NewRequest("POST", "/form", ioutil.NopCloser(strings.NewReader("a="+strings.Repeat("x", 32))))
+	req.ContentLength = -1
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	w := httptest.NewRecorder()
+	s.ServeHTTP(w, req)
+	if w.Code != 413 {
+		t.Errorf("expected 413 for chunked body got %d", w.Code)
+	}
+}
@@ -0,0 +1,206 @@
+package server
+
+import (
+	"fmt"
+	"html"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// StaticMount serves the files of a http.FileSystem under a URL prefix.
+// Its fields may be adjusted after Server.Static, before serving.
+type StaticMount struct {
+	prefix string
+	fs     http.FileSystem
+
+	// CacheControl is sent with every file, e.g. "public, max-age=3600".
+	CacheControl string
+	// Index is the file served for directories, "index.html" by default.
+	Index string
+	// Browse lists the content of directories without an index file.
+	Browse bool
+	// SPA serves the root index file for paths matching no file, so
+	// client side routers can handle them.
+	SPA bool
+	// Precompressed serves the ".gz" sibling of a file, when there is one
+	// and the client accepts gzip. It is enabled by default.
+	Precompressed bool
+}
+
+// Static mounts fs under the URL prefix. Mounts only serve GET and HEAD
+// requests matching no route, so routes take precedence over files.
+// Files get an ETag and a Last-Modified header and conditional and range
+// requests are honoured. Paths are cleaned before being looked up, so
+// they cannot escape fs.
+func (s *Server) Static(prefix string, fs http.FileSystem) *StaticMount {
+	m := &StaticMount{prefix: joinPath("", prefix), fs: fs, Index: "index.html", Precompressed: true}
+	s.statics = append(s.statics, m)
+	// longest prefixes first
+	sort.SliceStable(s.statics, func(i, j int) bool {
+		return len(s.statics[i].prefix) > len(s.statics[j].prefix)
+	})
+	return m
+}
+
+// serveStatic serves the request from the first mount its path is under.
+func (s *Server) serveStatic(ctx *Context) bool {
+	requestPath := ctx.Request.URL.Path
+	for _, m := range s.statics {
+		if hasPathPrefix(requestPath, m.prefix) {
+			name := requestPath
+			if m.prefix != "/" {
+				name = requestPath[len(m.prefix):]
+			}
+			if m.serve(ctx, name) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (m *StaticMount) serve(ctx *Context, name string) bool {
+	if containsDotDot(name) {
+		return false
+	}
+	name = path.Clean("/" + name)
+
+	f, err := m.fs.Open(name)
+	if err != nil {
+		if m.SPA && path.Ext(name) == "" {
+			return m.serveFile(ctx, path.Join("/", m.Index))
+		}
+		return false
+	}
+	info, err := f.Stat()
+	f.Close()
+	if err != nil {
+		return false
+	}
+
+	if info.IsDir() {
+		requestPath := ctx.Request.URL.Path
+		if !strings.HasSuffix(requestPath, "/") {
+			ctx.Redirect(http.StatusMovedPermanently, path.Base(requestPath)+"/")
+			return true
+		}
+		if m.serveFile(ctx, path.Join(name, m.Index)) {
+			return true
+		}
+		if m.Browse {
+			return m.list(ctx, name)
+		}
+		if m.SPA {
+			return m.serveFile(ctx, path.Join("/", m.Index))
+		}
+		return false
+	}
+	return m.serveFile(ctx, name)
+}
+
+// serveFile serves the regular file name, or its precompressed sibling.
+func (m *StaticMount) serveFile(ctx *Context, name string) bool {
+	h := ctx.Header()
+	if m.Precompressed && acceptsGzip(ctx.Request.Header.Get("Accept-Encoding")) {
+		if gz, err := m.fs.Open(name + ".gz"); err == nil {
+			defer gz.Close()
+			if info, err := gz.Stat(); err == nil && !info.IsDir() {
+				ctype := mime.TypeByExtension(path.Ext(name))
+				if ctype == "" {
+					ctype = "application/octet-stream"
+				}
+				h.Set("Content-Type", ctype)
+				h.Set("Content-Encoding", "gzip")
+				h.Add("Vary", "Accept-Encoding")
+				m.setCacheHeaders(h, info)
+				http.ServeContent(ctx.ResponseWriter, ctx.Request, name, info.ModTime(), gz)
+				return true
+			}
+		}
+	}
+
+	f, err := m.fs.Open(name)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil || info.IsDir() {
+		return false
+	}
+	// let ServeContent detect the type from the extension or the content
+	h.Del("Content-Type")
+	m.setCacheHeaders(h, info)
+	http.ServeContent(ctx.ResponseWriter, ctx.Request, name, info.ModTime(), f)
+	return true
+}
+
+func (m *StaticMount) setCacheHeaders(h http.Header, info os.FileInfo) {
+	h.Set("ETag", fmt.Sprintf(`W/"%x-%x"`, info.Size(), info.ModTime().UnixNano()))
+	if m.CacheControl != "" {
+		h.Set("Cache-Control", m.CacheControl)
+	}
+}
+
+// list writes an HTML listing of the directory name.
+func (m *StaticMount) list(ctx *Context, name string) bool {
+	d, err := m.fs.Open(name)
+	if err != nil {
+		return false
+	}
+	defer d.Close()
+	infos, err := d.Readdir(-1)
+	if err != nil {
+		return false
+	}
+	sort.Slice(infos, func(i, j int) bool { return infos[i].Name() < infos[j].Name() })
+
+	ctx.SetHeader("Content-Type", "text/html; charset=utf-8", true)
+	ctx.WriteString("<pre>\n")
+	for _, info := range infos {
+		n := info.Name()
+		if info.IsDir() {
+			n += "/"
+		}
+		u := url.URL{Path: n}
+		ctx.WriteString("<a href=\"" + html.EscapeString(u.String()) + "\">" + html.EscapeString(n) + "</a>\n")
+	}
+	ctx.WriteString("</pre>\n")
+	return true
+}
+
+// acceptsGzip reports whether the Accept-Encoding header allows gzip.
+func acceptsGzip(header string) bool {
+	for _, part := range strings.Split(header, ",") {
+		name, q := part, "1"
+		if i := strings.IndexByte(part, ';'); i >= 0 {
+			name = part[:i]
+			q = strings.TrimPrefix(strings.TrimSpace(part[i+1:]), "q=")
+		}
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "gzip" || name == "*" {
+			f, err := strconv.ParseFloat(q, 64)
+			return err == nil && f > 0
+		}
+	}
+	return false
+}
+
+// containsDotDot reports whether a path has a ".." element.
+func containsDotDot(p string) bool {
+	if !strings.Contains(p, "..") {
+		return false
+	}
+	for _, elem := range strings.FieldsFunc(p, func(r rune) bool { return r == '/' || r == '\\' }) {
+		if elem == ".." {
+			return true
+		}
+	}
+	return false
+}
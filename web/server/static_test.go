@@ -0,0 +1,94 @@
+package server
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestStatic(t *testing.T) {
+	root := filepath.Join(testDir, "public")
+	os.MkdirAll(filepath.Join(root, "css"), 0755)
+	os.MkdirAll(filepath.Join(root, "docs"), 0755)
+	ioutil.WriteFile(filepath.Join(root, "index.html"), []byte("<html>app</html>"), 0644)
+	ioutil.WriteFile(filepath.Join(root, "css", "site.css"), []byte("body{}"), 0644)
+	ioutil.WriteFile(filepath.Join(root, "docs", "a.txt"), []byte("a"), 0644)
+	ioutil.WriteFile(filepath.Join(testDir, "secret.txt"), []byte("secret"), 0644)
+	var gz bytes.Buffer
+	zw := gzip.NewWriter(&gz)
+	zw.Write([]byte("console.log(1)"))
+	zw.Close()
+	ioutil.WriteFile(filepath.Join(root, "app.js"), []byte("console.log(1)"), 0644)
+	ioutil.WriteFile(filepath.Join(root, "app.js.gz"), gz.Bytes(), 0644)
+
+	s := newTestServer(t)
+	s.Get("/css/site.css", func() string { return "route" })
+	m := s.Static("/", http.Dir(root))
+	m.CacheControl = "public, max-age=60"
+	m.SPA = true
+	docs := s.Static("/files", http.Dir(root))
+	docs.Browse = true
+
+	tests := []struct {
+		path     string
+		status   int
+		body     string
+		ctype    string
+		encoding string
+	}{
+		{"/css/site.css", 200, "route", "text/html; charset=utf-8", ""},
+		{"/index.html", 200, "<html>app</html>", "text/html; charset=utf-8", ""},
+		{"/users/42", 200, "<html>app</html>", "text/html; charset=utf-8", ""},
+		{"/missing.js", 404, "Page not found", "text/html; charset=utf-8", ""},
+		{"/app.js", 200, gz.String(), "text/javascript; charset=utf-8", "gzip"},
+		{"/files/css/site.css", 200, "body{}", "text/css; charset=utf-8", ""},
+		{"/files/docs/", 200, "<pre>\n<a href=\"a.txt\">a.txt</a>\n</pre>\n", "text/html; charset=utf-8", ""},
+		{"/files/docs", 301, "", "", ""},
+		{"/files/../secret.txt", 404, "Page not found", "text/html; charset=utf-8", ""},
+	}
+	for _, test := range tests {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.URL.Path = test.path
+		req.Header.Set("Accept-Encoding", "gzip")
+		w := httptest.NewRecorder()
+		s.ServeHTTP(w, req)
+		if w.Code != test.status {
+			t.Errorf("%s: expected status %d got %d", test.path, test.status, w.Code)
+			continue
+		}
+		if test.status == 301 {
+			continue
+		}
+		if w.Body.String() != test.body {
+			t.Errorf("%s: expected body %q got %q", test.path, test.body, w.Body.String())
+		}
+		if got := w.Header().Get("Content-Type"); got != test.ctype {
+			t.Errorf("%s: expected content type %q got %q", test.path, test.ctype, got)
+		}
+		if got := w.Header().Get("Content-Encoding"); got != test.encoding {
+			t.Errorf("%s: expected encoding %q got %q", test.path, test.encoding, got)
+		}
+	}
+
+	// conditional requests are answered from the ETag
+	w := doRequest(s, "GET", "/files/css/site.css", "")
+	etag := w.Header().Get("ETag")
+	if etag == "" || w.Header().Get("Last-Modified") == "" {
+		t.Fatalf("expected ETag and Last-Modified headers")
+	}
+	req := httptest.NewRequest("GET", "/files/css/site.css", nil)
+	req.Header.Set("If-None-Match", etag)
+	w = httptest.NewRecorder()
+	s.ServeHTTP(w, req)
+	if w.Code != 304 {
+		t.Errorf("expected 304 got %d", w.Code)
+	}
+	if w := doRequest(s, "GET", "/index.html", ""); w.Header().Get("Cache-Control") != "public, max-age=60" {
+		t.Errorf("expected Cache-Control header got %q", w.Header().Get("Cache-Control"))
+	}
+}
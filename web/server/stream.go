@@ -0,0 +1,138 @@
+package server
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Flush sends the data written so far to the client, if the underlying
+// ResponseWriter supports it.
+func (ctx *Context) Flush() {
+	if f, ok := ctx.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Stream calls step repeatedly, flushing after each call, until step
+// returns false or the client goes away. It reports whether the client
+// disconnected before the stream ended.
+func (ctx *Context) Stream(step func(w io.Writer) bool) bool {
+	done := ctx.Request.Context().Done()
+	for {
+		select {
+		case <-done:
+			return true
+		default:
+		}
+		keepOpen := step(ctx.ResponseWriter)
+		ctx.Flush()
+		if !keepOpen {
+			return false
+		}
+	}
+}
+
+// SSEvent is a Server-Sent Event. Data may span several lines. Retry,
+// when set, tells the client how long to wait before reconnecting.
+type SSEvent struct {
+	ID    string
+	Event string
+	Data  string
+	Retry time.Duration
+}
+
+// LastEventID returns the ID of the last event received by a
+// reconnecting Server-Sent Events client.
+func (ctx *Context) LastEventID() string {
+	return ctx.Request.Header.Get("Last-Event-ID")
+}
+
+// startSSE writes the Server-Sent Events headers once.
+func (ctx *Context) startSSE() {
+	if ctx.sseStarted {
+		return
+	}
+	ctx.sseStarted = true
+	h := ctx.Header()
+	h.Set("Content-Type", "text/event-stream")
+	h.Set("Cache-Control", "no-cache")
+	h.Set("Connection", "keep-alive")
+	h.Set("X-Accel-Buffering", "no")
+	h.Del("Content-Length")
+	ctx.WriteHeader(http.StatusOK)
+}
+
+// errInvalidSSEvent is returned for the events whose ID or Event would
+// end their line and inject other fields.
+var errInvalidSSEvent = errors.New("sse: ID and Event must not contain line breaks, nor ID a NUL")
+
+// SSEvent writes ev to the client and flushes it. The event stream
+// headers are sent with the first event.
+func (ctx *Context) SSEvent(ev SSEvent) error {
+	if strings.ContainsAny(ev.ID, "\r\n\x00") || strings.ContainsAny(ev.Event, "\r\n") {
+		return errInvalidSSEvent
+	}
+	ctx.startSSE()
+	var buf bytes.Buffer
+	if ev.ID != "" {
+		buf.WriteString("id: " + ev.ID + "\n")
+	}
+	if ev.Event != "" {
+		buf.WriteString("event: " + ev.Event + "\n")
+	}
+	if ev.Retry > 0 {
+		buf.WriteString("retry: " + strconv.FormatInt(int64(ev.Retry/time.Millisecond), 10) + "\n")
+	}
+	// a lone \r ends a line too
+	data := strings.Replace(strings.Replace(ev.Data, "\r\n", "\n", -1), "\r", "\n", -1)
+	for _, line := range strings.Split(data, "\n") {
+		buf.WriteString("data: " + line + "\n")
+	}
+	buf.WriteString("\n")
+	if _, err := ctx.ResponseWriter.Write(buf.Bytes()); err != nil {
+		return err
+	}
+	ctx.Flush()
+	return nil
+}
+
+// SSEStream sends the events received from events until the channel is
+// closed or the client disconnects, in which case the request context
+// error is returned. When heartbeat is positive a comment line is sent
+// after each heartbeat of inactivity to keep proxies from closing the
+// connection.
+func (ctx *Context) SSEStream(events <-chan SSEvent, heartbeat time.Duration) error {
+	ctx.startSSE()
+	ctx.Flush()
+
+	var tick <-chan time.Time
+	if heartbeat > 0 {
+		ticker := time.NewTicker(heartbeat)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+	reqCtx := ctx.Request.Context()
+	for {
+		select {
+		case <-reqCtx.Done():
+			return reqCtx.Err()
+		case ev, ok := <-events:
+			if !ok {
+				return nil
+			}
+			if err := ctx.SSEvent(ev); err != nil {
+				return err
+			}
+		case <-tick:
+			if _, err := io.WriteString(ctx.ResponseWriter, ": ping\n\n"); err != nil {
+				return err
+			}
+			ctx.Flush()
+		}
+	}
+}
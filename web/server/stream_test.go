@@ -0,0 +1,89 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestSSEStream(t *testing.T) {
+	s := newTestServer(t)
+	s.Get("/events", func(ctx *Context) {
+		events := make(chan SSEvent)
+		go func() {
+			events <- SSEvent{ID: "1", Event: "greeting", Data: "hello\nworld", Retry: 2 * time.Second}
+			time.Sleep(30 * time.Millisecond)
+			events <- SSEvent{ID: "2", Data: "bye"}
+			close(events)
+		}()
+		ctx.SSEStream(events, 10*time.Millisecond)
+	})
+
+	w := doRequest(s, "GET", "/events", "")
+	if ct := w.Header().Get("Content-Type"); ct != "text/event-stream" {
+		t.Errorf("unexpected content type %s", ct)
+	}
+	first := "id: 1\nevent: greeting\nretry: 2000\ndata: hello\ndata: world\n\n"
+	last := "id: 2\ndata: bye\n\n"
+	body := w.Body.String()
+	if len(body) < len(first)+len(last) || body[:len(first)] != first || body[len(body)-len(last):] != last {
+		t.Errorf("unexpected event stream %q", body)
+	}
+	if body[len(first):len(body)-len(last)] == "" {
+		t.Errorf("expected heartbeats between events got %q", body)
+	}
+}
+
+func TestStreamClientDisconnect(t *testing.T) {
+	s := newTestServer(t)
+	var disconnected bool
+	reqCtx, cancel := context.WithCancel(context.Background())
+	s.Get("/stream", func(ctx *Context) {
+		i := 0
+		disconnected = ctx.Stream(func(w io.Writer) bool {
+			i++
+			fmt.Fprintf(w, "%d\n", i)
+			if i == 3 {
+				cancel()
+			}
+			return i < 10
+		})
+	})
+	req := httptest.NewRequest("GET", "/stream", nil).WithContext(reqCtx)
+	w := httptest.NewRecorder()
+	s.ServeHTTP(w, req)
+	if !disconnected || w.Body.String() != "1\n2\n3\n" || !w.Flushed {
+		t.Errorf("unexpected stream %q disconnected=%v", w.Body.String(), disconnected)
+	}
+}
+
+func TestSSEventInjection(t *testing.T) {
+	s := newTestServer(t)
+	var errs []error
+	s.Get("/events", func(ctx *Context) {
+		for _, ev := range []SSEvent{
+			{ID: "1\ndata: injected", Data: "x"},
+			{ID: "1\x00", Data: "x"},
+			{Event: "a\rid: 5", Data: "x"},
+			{ID: "2", Data: "a\rid: 5\r\nb"},
+		} {
+			errs = append(errs, ctx.SSEvent(ev))
+		}
+	})
+
+	w := doRequest(s, "GET", "/events", "")
+	for i, err := range errs[:3] {
+		if err != errInvalidSSEvent {
+			t.Errorf("event %d: expected it to be refused got %v", i, err)
+		}
+	}
+	if errs[3] != nil {
+		t.Errorf("unexpected error %v", errs[3])
+	}
+	if body := w.Body.String(); body != "id: 2\ndata: a\ndata: id: 5\ndata: b\n\n" {
+		t.Errorf("unexpected event stream %q", body)
+	}
+}
@@ -0,0 +1,215 @@
+package server
+
+import (
+	"bytes"
+	"errors"
+	"github.com/widaT/golib/time2"
+	"html/template"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// TemplateFuncs are the functions available to the templates of every
+// server, on top of the html/template builtins.
+var TemplateFuncs = template.FuncMap{
+	"date": time2.OnlyDate,
+	"time": time2.OnlyTime,
+	"datetime": func(t time.Time) string {
+		return t.Format(time2.DEFAILT_FROMAT)
+	},
+	"unixtime": func(timestamp int64) string {
+		s, _ := time2.TimestampToTime(timestamp)
+		return s
+	},
+	"formatTime": func(t time.Time, layout string) string {
+		return t.Format(layout)
+	},
+}
+
+// templateSet holds the templates of a directory: a set per page, made of
+// the page, the layouts and the partials.
+type templateSet struct {
+	dir   string
+	ext   string
+	funcs template.FuncMap
+
+	mu      sync.RWMutex
+	pages   map[string]*template.Template
+	version string
+}
+
+// LoadTemplates parses the templates of dir with the extension ext, like
+// ".html", for Context.Render. Templates are named after their path
+// relative to dir, without the extension, like "users/index". The ones in
+// the "layouts" and "partials" subdirectories are shared by every page: a
+// page uses a layout with {{template "layouts/base" .}} and defines the
+// blocks the layout calls. Templates may use TemplateFuncs and the funcs
+// added with AddTemplateFunc. With Config.TemplateReload the templates are
+// parsed again when their files change.
+func (s *Server) LoadTemplates(dir, ext string) error {
+	funcs := template.FuncMap{}
+	for name, fn := range TemplateFuncs {
+		funcs[name] = fn
+	}
+	for name, fn := range s.templateFuncs {
+		funcs[name] = fn
+	}
+	ts := &templateSet{dir: dir, ext: ext, funcs: funcs}
+	if err := ts.load(); err != nil {
+		return err
+	}
+	s.templates = ts
+	return nil
+}
+
+// AddTemplateFunc makes fn available to the templates as name. It must be
+// called before LoadTemplates.
+func (s *Server) AddTemplateFunc(name string, fn interface{}) {
+	if s.templateFuncs == nil {
+		s.templateFuncs = template.FuncMap{}
+	}
+	s.templateFuncs[name] = fn
+}
+
+// files returns the template files by name, and a version changing when
+// one of them is added, removed or modified.
+func (ts *templateSet) files() (map[string]string, string, error) {
+	files := map[string]string{}
+	var version bytes.Buffer
+	err := filepath.Walk(ts.dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() || !strings.HasSuffix(path, ts.ext) {
+			return nil
+		}
+		rel, err := filepath.Rel(ts.dir, path)
+		if err != nil {
+			return err
+		}
+		files[strings.TrimSuffix(filepath.ToSlash(rel), ts.ext)] = path
+		version.WriteString(path + info.ModTime().String() + "\n")
+		return nil
+	})
+	return files, version.String(), err
+}
+
+func (ts *templateSet) load() error {
+	files, version, err := ts.files()
+	if err != nil {
+		return err
+	}
+
+	base := template.New("").Funcs(ts.funcs)
+	var pages []string
+	for name, path := range files {
+		if !strings.HasPrefix(name, "layouts/") && !strings.HasPrefix(name, "partials/") {
+			pages = append(pages, name)
+			continue
+		}
+		if err := parseFile(base.New(name), path); err != nil {
+			return err
+		}
+	}
+
+	sets := map[string]*template.Template{}
+	for _, name := range pages {
+		set, err := base.Clone()
+		if err != nil {
+			return err
+		}
+		if err := parseFile(set.New(name), files[name]); err != nil {
+			return err
+		}
+		sets[name] = set
+	}
+	// the shared templates can be rendered by themselves too
+	for name := range files {
+		if _, ok := sets[name]; !ok {
+			sets[name] = base
+		}
+	}
+
+	ts.mu.Lock()
+	ts.pages, ts.version = sets, version
+	ts.mu.Unlock()
+	return nil
+}
+
+func parseFile(t *template.Template, path string) error {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	_, err = t.Parse(string(b))
+	return err
+}
+
+// lookup returns the set of the template name, reloading the templates
+// first if reload is set and their files changed.
+func (ts *templateSet) lookup(name string, reload bool) (*template.Template, error) {
+	if reload {
+		_, version, err := ts.files()
+		if err != nil {
+			return nil, err
+		}
+		ts.mu.RLock()
+		changed := version != ts.version
+		ts.mu.RUnlock()
+		if changed {
+			if err := ts.load(); err != nil {
+				return nil, err
+			}
+		}
+	}
+	ts.mu.RLock()
+	defer ts.mu.RUnlock()
+	t, ok := ts.pages[name]
+	if !ok {
+		return nil, errors.New("render: no template " + name)
+	}
+	return t, nil
+}
+
+// Render executes the template name with data and writes it with status.
+// When data is a map[string]interface{}, the CSRF token and its hidden
+// field are added to it as "csrfToken" and "csrfField" for the forms of
+// the page. Template errors are answered with a 500 through the error
+// handler, nothing of the page being written, and returned.
+func (ctx *Context) Render(status int, name string, data interface{}) error {
+	err := errors.New("render: no templates loaded")
+	var buf bytes.Buffer
+	if ts := ctx.Server.templates; ts != nil {
+		var t *template.Template
+		t, err = ts.lookup(name, ctx.Server.Config.TemplateReload)
+		if err == nil {
+			err = t.ExecuteTemplate(&buf, name, ctx.templateData(data))
+		}
+	}
+	if err != nil {
+		ctx.HandleError(HTTPError{Code: 500, Err: err})
+		return err
+	}
+	ctx.SetHeader("Content-Type", "text/html; charset=utf-8", true)
+	writeBody(ctx, status, buf.Bytes())
+	return nil
+}
+
+// templateData adds the CSRF token to a map of data.
+func (ctx *Context) templateData(data interface{}) interface{} {
+	m, ok := data.(map[string]interface{})
+	if !ok || ctx.csrfToken == "" {
+		return data
+	}
+	copied := make(map[string]interface{}, len(m)+2)
+	copied["csrfToken"] = ctx.CSRFToken()
+	copied["csrfField"] = ctx.CSRFField()
+	for k, v := range m {
+		copied[k] = v
+	}
+	return copied
+}
@@ -0,0 +1,118 @@
+package server
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func writeTemplates(t *testing.T, dir string, files map[string]string) {
+	for name, content := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestRender(t *testing.T) {
+	dir, err := ioutil.TempDir(testDir, "templates")
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeTemplates(t, dir, map[string]string{
+		"layouts/base.html":  `<title>{{template "title" .}}</title>{{template "partials/nav" .}}{{template "content" .}}`,
+		"partials/nav.html":  `<nav>{{.User}}</nav>`,
+		"users/index.html":   `{{template "layouts/base" .}}{{define "title"}}Users{{end}}{{define "content"}}<p>{{.User}} {{date .Since}} {{shout .User}}</p>{{end}}`,
+		"about.html":         `{{template "layouts/base" .}}{{define "title"}}About{{end}}{{define "content"}}<p>about</p>{{end}}`,
+		"broken/render.html": `{{.Missing.Field}}`,
+		"form.html":          `<form>{{.csrfField}}</form>`,
+		"notes.txt":          `not a template`,
+	})
+
+	s := newTestServer(t)
+	s.AddTemplateFunc("shout", strings.ToUpper)
+	if err := s.LoadTemplates(dir, ".html"); err != nil {
+		t.Fatal(err)
+	}
+	data := map[string]interface{}{"User": "<ann>", "Since": time.Date(2019, 7, 1, 0, 0, 0, 0, time.UTC)}
+	s.Get("/users", func(ctx *Context) { ctx.Render(201, "users/index", data) })
+	s.Get("/about", func(ctx *Context) { ctx.Render(200, "about", data) })
+	s.Get("/broken", func(ctx *Context) { ctx.Render(200, "broken/render", "x") })
+	s.Get("/missing", func(ctx *Context) { ctx.Render(200, "notes", nil) })
+	s.Get("/form", func(ctx *Context) { ctx.Render(200, "form", map[string]interface{}{}) },
+		func(next HandlerFunc) HandlerFunc {
+			return func(ctx *Context) {
+				ctx.csrfToken, ctx.csrfField = "tok", "_csrf"
+				next(ctx)
+			}
+		})
+
+	tests := []struct {
+		path string
+		code int
+		body string
+	}{
+		{"/users", 201, "<title>Users</title><nav>&lt;ann&gt;</nav><p>&lt;ann&gt; 2019-07-01 &lt;ANN&gt;</p>"},
+		{"/about", 200, "<title>About</title><nav>&lt;ann&gt;</nav><p>about</p>"},
+		{"/broken", 500, "Internal Server Error"},
+		{"/missing", 500, "Internal Server Error"},
+		{"/form", 200, `<form><input type="hidden" name="_csrf" value="tok"></form>`},
+	}
+	for _, test := range tests {
+		w := doRequest(s, "GET", test.path, "")
+		if w.Code != test.code || w.Body.String() != test.body {
+			t.Errorf("%s: got %d %q, want %d %q", test.path, w.Code, w.Body.String(), test.code, test.body)
+		}
+		if test.code != 500 && w.Header().Get("Content-Type") != "text/html; charset=utf-8" {
+			t.Errorf("%s: got Content-Type %q", test.path, w.Header().Get("Content-Type"))
+		}
+	}
+
+	if err := s.LoadTemplates(filepath.Join(dir, "missing"), ".html"); err == nil {
+		t.Error("loading a missing directory: expected an error")
+	}
+	writeTemplates(t, dir, map[string]string{"bad.html": `{{if}}`})
+	if err := s.LoadTemplates(dir, ".html"); err == nil {
+		t.Error("loading a bad template: expected an error")
+	}
+}
+
+func TestRenderReload(t *testing.T) {
+	dir, err := ioutil.TempDir(testDir, "templates")
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeTemplates(t, dir, map[string]string{"page.html": "v1"})
+	s := newTestServer(t)
+	if err := s.LoadTemplates(dir, ".html"); err != nil {
+		t.Fatal(err)
+	}
+	s.Get("/page", func(ctx *Context) { ctx.Render(200, "page", nil) })
+
+	update := func(content string) {
+		writeTemplates(t, dir, map[string]string{"page.html": content})
+		// make the change visible despite a coarse file system clock
+		later := time.Now().Add(time.Hour)
+		os.Chtimes(filepath.Join(dir, "page.html"), later, later)
+	}
+
+	update("v2")
+	if w := doRequest(s, "GET", "/page", ""); w.Body.String() != "v1" {
+		t.Errorf("without reload: got %q", w.Body.String())
+	}
+	s.Config.TemplateReload = true
+	if w := doRequest(s, "GET", "/page", ""); w.Body.String() != "v2" {
+		t.Errorf("with reload: got %q", w.Body.String())
+	}
+	update("{{if}}")
+	if w := doRequest(s, "GET", "/page", ""); w.Code != 500 {
+		t.Errorf("broken reload: got %d %q", w.Code, w.Body.String())
+	}
+}
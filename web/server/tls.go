@@ -0,0 +1,170 @@
+package server
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"io/ioutil"
+	"os"
+	"sync"
+	"time"
+)
+
+// defaultTLSReloadInterval is how often the certificate files are checked
+// for changes when Config.TLSReloadInterval is not set.
+const defaultTLSReloadInterval = 10 * time.Second
+
+// RunTLSFiles serves HTTPS requests for s with the certificate and key
+// stored in certFile and keyFile. The files are watched and the new
+// certificate is used for the next handshakes once they change, so
+// certificates can be rotated without a restart.
+// When Config.TLSClientCAFile is set, clients must present a certificate
+// signed by one of its CAs, see Context.ClientCert.
+func (s *Server) RunTLSFiles(addr string, certFile string, keyFile string) error {
+	s.initServer()
+	config, err := s.tlsConfig(certFile, keyFile)
+	if err != nil {
+		s.Logger.Error("TLS: %v", err)
+		return err
+	}
+	l, err := tls.Listen("tcp", addr, config)
+	if err != nil {
+		s.Logger.Error("Listen: %v", err)
+		return err
+	}
+	s.Logger.Printf("web.go serving %s", addr)
+	return s.serve(context.Background(), l)
+}
+
+// tlsConfig builds the TLS configuration used by RunTLSFiles.
+func (s *Server) tlsConfig(certFile string, keyFile string) (*tls.Config, error) {
+	interval := s.Config.TLSReloadInterval
+	if interval <= 0 {
+		interval = defaultTLSReloadInterval
+	}
+	reloader, err := newCertReloader(certFile, keyFile, interval, s)
+	if err != nil {
+		return nil, err
+	}
+	config := &tls.Config{GetCertificate: reloader.GetCertificate}
+
+	if s.Config.TLSClientCAFile != "" {
+		pem, err := ioutil.ReadFile(s.Config.TLSClientCAFile)
+		if err != nil {
+			return nil, err
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, errors.New("no certificate found in " + s.Config.TLSClientCAFile)
+		}
+		config.ClientCAs = pool
+		config.ClientAuth = s.Config.TLSClientAuth
+		if config.ClientAuth == tls.NoClientCert {
+			config.ClientAuth = tls.RequireAndVerifyClientCert
+		}
+	}
+	return config, nil
+}
+
+// certReloader serves a certificate loaded from files, reloading it when
+// the modification time of the files changes.
+type certReloader struct {
+	certFile string
+	keyFile  string
+	interval time.Duration
+	server   *Server
+
+	mu      sync.RWMutex
+	cert    *tls.Certificate
+	modTime time.Time
+	checked time.Time
+}
+
+func newCertReloader(certFile string, keyFile string, interval time.Duration, s *Server) (*certReloader, error) {
+	r := &certReloader{certFile: certFile, keyFile: keyFile, interval: interval, server: s}
+	modTime, err := r.latestModTime()
+	if err != nil {
+		return nil, err
+	}
+	if err := r.load(modTime); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// GetCertificate implements tls.Config.GetCertificate.
+func (r *certReloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	r.maybeReload()
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.cert, nil
+}
+
+// maybeReload checks the files at most once per interval and loads them
+// again if they changed. On failure the current certificate is kept.
+func (r *certReloader) maybeReload() {
+	r.mu.RLock()
+	due := time.Since(r.checked) >= r.interval
+	r.mu.RUnlock()
+	if !due {
+		return
+	}
+
+	r.mu.Lock()
+	r.checked = time.Now()
+	current := r.modTime
+	r.mu.Unlock()
+
+	modTime, err := r.latestModTime()
+	if err != nil {
+		r.server.Logger.Error("TLS: %v", err)
+		return
+	}
+	if !modTime.After(current) {
+		return
+	}
+	if err := r.load(modTime); err != nil {
+		r.server.Logger.Error("TLS: reloading certificate: %v", err)
+		return
+	}
+	r.server.Logger.Printf("TLS: reloaded certificate %s", r.certFile)
+}
+
+func (r *certReloader) load(modTime time.Time) error {
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		return err
+	}
+	r.mu.Lock()
+	r.cert = &cert
+	r.modTime = modTime
+	r.checked = time.Now()
+	r.mu.Unlock()
+	return nil
+}
+
+func (r *certReloader) latestModTime() (time.Time, error) {
+	var latest time.Time
+	for _, name := range []string{r.certFile, r.keyFile} {
+		info, err := os.Stat(name)
+		if err != nil {
+			return latest, err
+		}
+		if info.ModTime().After(latest) {
+			latest = info.ModTime()
+		}
+	}
+	return latest, nil
+}
+
+// ClientCert returns the verified certificate the client authenticated
+// with, or nil when the connection is not TLS or the client presented no
+// verified certificate.
+func (ctx *Context) ClientCert() *x509.Certificate {
+	state := ctx.Request.TLS
+	if state == nil || len(state.VerifiedChains) == 0 || len(state.VerifiedChains[0]) == 0 {
+		return nil
+	}
+	return state.VerifiedChains[0][0]
+}
@@ -0,0 +1,188 @@
+package server
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// newTestCert returns a certificate for name and its key, signed by
+// parent or self-signed when parent is nil.
+func newTestCert(t *testing.T, name string, isCA bool, parent *x509.Certificate, parentKey *ecdsa.PrivateKey) (*x509.Certificate, *ecdsa.PrivateKey) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	if ip := net.ParseIP(name); ip != nil {
+		template.IPAddresses = []net.IP{ip}
+	} else {
+		template.DNSNames = []string{name}
+	}
+	if isCA {
+		template.IsCA = true
+		template.BasicConstraintsValid = true
+		template.KeyUsage = x509.KeyUsageCertSign
+	}
+	if parent == nil {
+		parent, parentKey = template, key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, parent, &key.PublicKey, parentKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cert, key
+}
+
+// writeCert writes cert to certFile and key to keyFile, PEM encoded.
+func writeCert(t *testing.T, cert *x509.Certificate, key *ecdsa.PrivateKey, certFile string, keyFile string) {
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	certPem := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw})
+	keyPem := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer})
+	if err := ioutil.WriteFile(certFile, certPem, 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(keyFile, keyPem, 0600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// writeTestCert writes a self-signed certificate for name to certFile and
+// its key to keyFile.
+func writeTestCert(t *testing.T, name string, certFile string, keyFile string) {
+	cert, key := newTestCert(t, name, false, nil, nil)
+	writeCert(t, cert, key, certFile, keyFile)
+}
+
+func TestCertReloader(t *testing.T) {
+	s := newTestServer(t)
+	certFile := filepath.Join(testDir, "reload.crt")
+	keyFile := filepath.Join(testDir, "reload.key")
+	writeTestCert(t, "old.example.com", certFile, keyFile)
+
+	r, err := newCertReloader(certFile, keyFile, time.Nanosecond, s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	commonName := func() string {
+		cert, _ := r.GetCertificate(nil)
+		leaf, err := x509.ParseCertificate(cert.Certificate[0])
+		if err != nil {
+			t.Fatal(err)
+		}
+		return leaf.Subject.CommonName
+	}
+	if cn := commonName(); cn != "old.example.com" {
+		t.Fatalf("expected old.example.com got %s", cn)
+	}
+
+	writeTestCert(t, "new.example.com", certFile, keyFile)
+	future := time.Now().Add(time.Minute)
+	os.Chtimes(certFile, future, future)
+	if cn := commonName(); cn != "new.example.com" {
+		t.Errorf("expected new.example.com after reload got %s", cn)
+	}
+
+	// a broken file keeps the current certificate
+	ioutil.WriteFile(keyFile, []byte("broken"), 0600)
+	later := future.Add(time.Minute)
+	os.Chtimes(keyFile, later, later)
+	if cn := commonName(); cn != "new.example.com" {
+		t.Errorf("expected new.example.com to be kept got %s", cn)
+	}
+}
+
+func TestRunTLSFilesClientCert(t *testing.T) {
+	ca, caKey := newTestCert(t, "Test CA", true, nil, nil)
+	caFile := filepath.Join(testDir, "mtls-ca.crt")
+	writeCert(t, ca, caKey, caFile, filepath.Join(testDir, "mtls-ca.key"))
+	serverCert, serverKey := newTestCert(t, "127.0.0.1", false, ca, caKey)
+	certFile := filepath.Join(testDir, "mtls-server.crt")
+	keyFile := filepath.Join(testDir, "mtls-server.key")
+	writeCert(t, serverCert, serverKey, certFile, keyFile)
+	clientCert, clientKey := newTestCert(t, "client.example.com", false, ca, caKey)
+
+	s := newTestServer(t)
+	s.Config.TLSClientCAFile = caFile
+	s.Config.TLSClientAuth = tls.RequireAndVerifyClientCert
+	s.Get("/whoami", func(ctx *Context) string {
+		if cert := ctx.ClientCert(); cert != nil {
+			return cert.Subject.CommonName
+		}
+		return "anonymous"
+	})
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := l.Addr().String()
+	l.Close()
+	runErr := make(chan error, 1)
+	go func() { runErr <- s.RunTLSFiles(addr, certFile, keyFile) }()
+	defer func() {
+		s.Close()
+		if err := <-runErr; err != nil {
+			t.Errorf("expected nil error got %v", err)
+		}
+	}()
+	for i := 0; i < 50; i++ {
+		var c net.Conn
+		if c, err = net.Dial("tcp", addr); err == nil {
+			c.Close()
+			break
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	roots := x509.NewCertPool()
+	roots.AddCert(ca)
+	get := func(certs []tls.Certificate) (string, error) {
+		client := &http.Client{Transport: &http.Transport{
+			TLSClientConfig:   &tls.Config{RootCAs: roots, Certificates: certs},
+			DisableKeepAlives: true,
+		}}
+		resp, err := client.Get("https://" + addr + "/whoami")
+		if err != nil {
+			return "", err
+		}
+		defer resp.Body.Close()
+		b, err := ioutil.ReadAll(resp.Body)
+		return string(b), err
+	}
+
+	cert := tls.Certificate{Certificate: [][]byte{clientCert.Raw}, PrivateKey: clientKey}
+	if body, err := get([]tls.Certificate{cert}); err != nil || body != "client.example.com" {
+		t.Errorf("expected client.example.com got %q, %v", body, err)
+	}
+	if body, err := get(nil); err == nil {
+		t.Errorf("expected a client without certificate to be refused, got %q", body)
+	}
+}
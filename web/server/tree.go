@@ -1,12 +1,28 @@
 package server
 
 import (
+	"regexp"
+	"sort"
 	"strings"
 )
 
+// segment kinds, in match priority order
+const (
+	staticSeg = iota
+	paramSeg
+	wildcardSeg
+)
+
+// Tree is a path-segment router. A pattern segment is either a literal,
+// a named parameter `:name` with an optional regex constraint `:id([0-9]+)`,
+// or a trailing catch-all `*name`. When several segments could match,
+// static segments win over params and params win over wildcards.
 type Tree struct {
-	prefix string
-	routers []*Tree
+	prefix   string
+	kind     int
+	name     string
+	reg      *regexp.Regexp
+	routers  []*Tree
 	runnable interface{}
 }
 
@@ -25,14 +41,14 @@ func (t *Tree) addTree(segments []string, tree *Tree) {
 	seg := segments[0]
 
 	if len(segments) == 1 {
-		tree.prefix = seg
-		t.routers = append(t.routers, tree)
+		tree.setPrefix(seg)
+		t.appendRouter(tree)
 		return
 	}
 
 	subTree := NewTree()
-	subTree.prefix = seg
-	t.routers = append(t.routers, subTree)
+	subTree.setPrefix(seg)
+	t.appendRouter(subTree)
 	subTree.addTree(segments[1:], tree)
 }
 
@@ -54,48 +70,131 @@ func (t *Tree) addseg(segments []string, route interface{}) {
 		}
 		if subTree == nil {
 			subTree = NewTree()
-			subTree.prefix = seg
-			t.routers = append(t.routers, subTree)
+			subTree.setPrefix(seg)
+			// the same param or wildcard under another name would never
+			// be reached, its routes being shadowed by the first one
+			for _, sub := range t.routers {
+				if sub.kind != staticSeg && sub.kind == subTree.kind && regString(sub.reg) == regString(subTree.reg) {
+					panic("segment " + seg + " conflicts with " + sub.prefix + ": parameters at the same position must have the same name")
+				}
+			}
+			t.appendRouter(subTree)
+		}
+		if subTree.kind == wildcardSeg && len(segments) > 1 {
+			panic("wildcard segment " + seg + " must be the last one")
 		}
 		subTree.addseg(segments[1:], route)
 	}
 }
 
+// setPrefix stores the raw segment and parses it into its kind,
+// parameter name and optional regex constraint.
+func (t *Tree) setPrefix(seg string) {
+	t.prefix = seg
+	t.kind = staticSeg
+	t.name = ""
+	t.reg = nil
+	if len(seg) < 2 {
+		return
+	}
+	switch seg[0] {
+	case '*':
+		t.kind = wildcardSeg
+		t.name = seg[1:]
+	case ':':
+		t.kind = paramSeg
+		t.name = seg[1:]
+		if i := strings.IndexByte(seg, '('); i > 0 && seg[len(seg)-1] == ')' {
+			t.name = seg[1:i]
+			t.reg = regexp.MustCompile("^(?:" + seg[i+1:len(seg)-1] + ")$")
+		}
+	}
+}
+
+// regString returns the source of a segment constraint, empty without one.
+func regString(reg *regexp.Regexp) string {
+	if reg == nil {
+		return ""
+	}
+	return reg.String()
+}
+
+// appendRouter adds a child keeping static children first, then params,
+// then wildcards, each group in registration order.
+func (t *Tree) appendRouter(sub *Tree) {
+	t.routers = append(t.routers, sub)
+	sort.SliceStable(t.routers, func(i, j int) bool {
+		return t.routers[i].kind < t.routers[j].kind
+	})
+}
+
 func (t *Tree) Match(pattern string) (runnable interface{}) {
+	runnable, _ = t.MatchParams(pattern)
+	return
+}
+
+// MatchParams is like Match but also returns the values captured by
+// `:name` and `*name` segments.
+func (t *Tree) MatchParams(pattern string) (runnable interface{}, params map[string]string) {
 	if len(pattern) == 0 || pattern[0] != '/' {
-		return nil
+		return nil, nil
+	}
+	params = map[string]string{}
+	runnable = t.match(pattern[1:], pattern, params)
+	if runnable == nil {
+		return nil, nil
 	}
-	return t.match(pattern[1:], pattern)
+	return runnable, params
 }
 
-func (t *Tree) match(treePattern string, pattern string) (runnable interface{}) {
+func (t *Tree) match(treePattern string, pattern string, params map[string]string) (runnable interface{}) {
 	if len(pattern) > 0 {
 		i := 0
 		for ; i < len(pattern) && pattern[i] == '/'; i++ {
 		}
 		pattern = pattern[i:]
 	}
+	rest := pattern
 	var seg string
 	i, l := 0, len(pattern)
 	for ; i < l && pattern[i] != '/'; i++ {
 	}
 	if i == 0 {
-		return t.runnable
+		if t.runnable != nil {
+			return t.runnable
+		}
 	} else {
 		seg = pattern[:i]
 		pattern = pattern[i:]
 	}
 	for _, subTree := range t.routers {
-		if subTree.prefix == seg {
-			if len(pattern) != 0 && pattern[0] == '/' {
-				treePattern = pattern[1:]
-			} else {
-				treePattern = pattern
+		switch subTree.kind {
+		case staticSeg:
+			if i == 0 || subTree.prefix != seg {
+				continue
 			}
-			runnable = subTree.match(treePattern, pattern)
-			if runnable != nil {
-				break
+		case paramSeg:
+			if i == 0 || (subTree.reg != nil && !subTree.reg.MatchString(seg)) {
+				continue
+			}
+		case wildcardSeg:
+			if subTree.runnable == nil {
+				continue
+			}
+			params[subTree.name] = rest
+			return subTree.runnable
+		}
+		if len(pattern) != 0 && pattern[0] == '/' {
+			treePattern = pattern[1:]
+		} else {
+			treePattern = pattern
+		}
+		runnable = subTree.match(treePattern, pattern, params)
+		if runnable != nil {
+			if subTree.kind == paramSeg {
+				params[subTree.name] = seg
 			}
+			break
 		}
 	}
 	return runnable
@@ -107,4 +206,4 @@ func splitPath(key string) []string {
 		return []string{}
 	}
 	return strings.Split(key, "/")
-}
\ No newline at end of file
+}
@@ -0,0 +1,73 @@
+package server
+
+import (
+	"testing"
+)
+
+func TestTreeMatchParams(t *testing.T) {
+	tree := NewTree()
+	tree.AddRouter("/users/new", "new")
+	tree.AddRouter("/users/:id([0-9]+)", "user")
+	tree.AddRouter("/users/:id([0-9]+)/orders/:oid", "order")
+	tree.AddRouter("/users/:name", "byname")
+	tree.AddRouter("/static/*filepath", "static")
+	tree.AddRouter("/", "index")
+
+	tests := []struct {
+		path     string
+		runnable interface{}
+		params   map[string]string
+	}{
+		{"/", "index", nil},
+		{"/users/new", "new", nil},
+		{"/users/42", "user", map[string]string{"id": "42"}},
+		{"/users/42/orders/7", "order", map[string]string{"id": "42", "oid": "7"}},
+		{"/users/bob", "byname", map[string]string{"name": "bob"}},
+		{"/users/bob/orders/7", nil, nil},
+		{"/static/css/site.css", "static", map[string]string{"filepath": "css/site.css"}},
+		{"/static/", "static", map[string]string{"filepath": ""}},
+		{"/missing", nil, nil},
+	}
+	for _, test := range tests {
+		runnable, params := tree.MatchParams(test.path)
+		if runnable != test.runnable {
+			t.Errorf("%s: expected %v got %v", test.path, test.runnable, runnable)
+			continue
+		}
+		for k, v := range test.params {
+			if params[k] != v {
+				t.Errorf("%s: expected param %s=%q got %q", test.path, k, v, params[k])
+			}
+		}
+		if len(params) != len(test.params) {
+			t.Errorf("%s: expected params %v got %v", test.path, test.params, params)
+		}
+	}
+}
+
+func TestTreeConflictingParamNames(t *testing.T) {
+	tests := []struct {
+		first, second string
+		conflict      bool
+	}{
+		{"/users/:id", "/users/:id", false},
+		{"/users/:id", "/users/:uid", true},
+		{"/users/:id/posts", "/users/:uid/comments", true},
+		{"/users/:id([0-9]+)", "/users/:uid([0-9]+)", true},
+		{"/users/:id([0-9]+)", "/users/:name", false},
+		{"/files/*path", "/files/*name", true},
+		{"/users/new", "/users/:id", false},
+	}
+	for _, test := range tests {
+		tree := NewTree()
+		tree.AddRouter(test.first, "first")
+		func() {
+			defer func() {
+				if r := recover(); (r != nil) != test.conflict {
+					t.Errorf("%s then %s: expected conflict %v got %v", test.first, test.second, test.conflict, r)
+				}
+			}()
+			tree.AddRouter(test.second, "second")
+		}()
+	}
+}
@@ -0,0 +1,134 @@
+package server
+
+import (
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Errors returned by FormFile and FormFiles when a file is missing or an
+// upload breaks the limits of ServerConfig. Handlers returning them answer
+// 400 Bad Request, 413 Request Entity Too Large or 415 Unsupported Media
+// Type.
+var (
+	ErrMissingFile         = HTTPError{Code: http.StatusBadRequest, Message: "upload: no such file"}
+	ErrFileTooLarge        = HTTPError{Code: http.StatusRequestEntityTooLarge, Message: "upload: file too large"}
+	ErrUploadTooLarge      = HTTPError{Code: http.StatusRequestEntityTooLarge, Message: "upload: total upload size too large"}
+	ErrFileTypeNotAllowed  = HTTPError{Code: http.StatusUnsupportedMediaType, Message: "upload: file type not allowed"}
+	errNotMultipartRequest = HTTPError{Code: http.StatusBadRequest, Message: "upload: request is not multipart"}
+)
+
+// FormFile returns the first file uploaded under name, after checking it
+// against the upload limits of the server.
+func (ctx *Context) FormFile(name string) (*multipart.FileHeader, error) {
+	files, err := ctx.FormFiles(name)
+	if err != nil {
+		return nil, err
+	}
+	return files[0], nil
+}
+
+// FormFiles returns all the files uploaded under name, after checking
+// them against the upload limits of the server: the size of each file,
+// the total size of the uploaded files and their sniffed MIME type.
+func (ctx *Context) FormFiles(name string) ([]*multipart.FileHeader, error) {
+	ctx.parseForm()
+	if ctx.tooLarge {
+		return nil, ErrUploadTooLarge
+	}
+	form := ctx.Request.MultipartForm
+	if form == nil {
+		return nil, errNotMultipartRequest
+	}
+	files := form.File[name]
+	if len(files) == 0 {
+		return nil, ErrMissingFile
+	}
+
+	config := ctx.Server.Config
+	if config.MaxUploadSize > 0 {
+		var total int64
+		for _, fhs := range form.File {
+			for _, fh := range fhs {
+				total += fh.Size
+			}
+		}
+		if total > config.MaxUploadSize {
+			return nil, ErrUploadTooLarge
+		}
+	}
+	for _, fh := range files {
+		if config.MaxUploadFileSize > 0 && fh.Size > config.MaxUploadFileSize {
+			return nil, ErrFileTooLarge
+		}
+		if len(config.AllowedUploadTypes) > 0 {
+			ctype, err := sniffContentType(fh)
+			if err != nil {
+				return nil, err
+			}
+			if !mimeAllowed(ctype, config.AllowedUploadTypes) {
+				return nil, ErrFileTypeNotAllowed
+			}
+		}
+	}
+	return files, nil
+}
+
+// SaveUploadedFile streams the uploaded file fh to dst, creating the
+// parent directories as needed.
+func (ctx *Context) SaveUploadedFile(fh *multipart.FileHeader, dst string) error {
+	src, err := fh.Open()
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return err
+	}
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	if _, err = io.Copy(out, src); err != nil {
+		out.Close()
+		os.Remove(dst)
+		return err
+	}
+	return out.Close()
+}
+
+// sniffContentType detects the MIME type of an uploaded file from its
+// first 512 bytes, ignoring the type claimed by the client.
+func sniffContentType(fh *multipart.FileHeader) (string, error) {
+	f, err := fh.Open()
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	buf := make([]byte, 512)
+	n, err := io.ReadFull(f, buf)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return "", err
+	}
+	ctype, _, err := mime.ParseMediaType(http.DetectContentType(buf[:n]))
+	return ctype, err
+}
+
+// mimeAllowed reports whether ctype matches one of the allowed types,
+// which may use a wildcard subtype such as "image/*".
+func mimeAllowed(ctype string, allowed []string) bool {
+	for _, a := range allowed {
+		if a == ctype || a == "*/*" {
+			return true
+		}
+		if strings.HasSuffix(a, "/*") && strings.HasPrefix(ctype, a[:len(a)-1]) {
+			return true
+		}
+	}
+	return false
+}
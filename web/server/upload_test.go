@@ -0,0 +1,170 @@
+package server
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+)
+
+var pngHeader = []byte("\x89PNG\x0D\x0A\x1A\x0A" + "imagedata")
+
+func TestUpload(t *testing.T) {
+	s := newTestServer(t)
+	s.Config.AllowedUploadTypes = []string{"image/*"}
+	s.Config.MaxUploadFileSize = 64
+	dst := filepath.Join(testDir, "uploads", "avatar.png")
+	s.Post("/upload", func(ctx *Context) string {
+		fh, err := ctx.FormFile("avatar")
+		if err != nil {
+			return err.Error()
+		}
+		if err := ctx.SaveUploadedFile(fh, dst); err != nil {
+			return err.Error()
+		}
+		return "saved " + ctx.Params["title"]
+	})
+
+	tests := []struct {
+		content []byte
+		body    string
+	}{
+		{pngHeader, "saved me"},
+		{[]byte("plain text"), ErrFileTypeNotAllowed.Error()},
+		{append(pngHeader, make([]byte, 64)...), ErrFileTooLarge.Error()},
+	}
+	for _, test := range tests {
+		var buf bytes.Buffer
+		mw := multipart.NewWriter(&buf)
+		mw.WriteField("title", "me")
+		fw, _ := mw.CreateFormFile("avatar", "avatar.png")
+		fw.Write(test.content)
+		mw.Close()
+
+		req := httptest.NewRequest("POST", "/upload", &buf)
+		req.Header.Set("Content-Type", mw.FormDataContentType())
+		w := httptest.NewRecorder()
+		s.ServeHTTP(w, req)
+		if w.Body.String() != test.body {
+			t.Errorf("expected %q got %q", test.body, w.Body.String())
+		}
+	}
+
+	saved, err := ioutil.ReadFile(dst)
+	if err != nil || !bytes.Equal(saved, pngHeader) {
+		t.Errorf("unexpected saved file %q %v", saved, err)
+	}
+}
+
+// countingReader counts the bytes read from the request body.
+type countingReader struct {
+	r io.Reader
+	n int
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += n
+	return n, err
+}
+
+func TestUploadTooLarge(t *testing.T) {
+	s := newTestServer(t)
+	s.Config.MaxUploadSize = 1024
+	s.Post("/upload", func(ctx *Context) error {
+		_, err := ctx.FormFile("file")
+		return err
+	})
+
+	var buf bytes.Buffer
+	mw := multipart.NewWriter(&buf)
+	fw, _ := mw.CreateFormFile("file", "big.bin")
+	fw.Write(make([]byte, 1<<20))
+	mw.Close()
+
+	body := &countingReader{r: &buf}
+	req := httptest.NewRequest("POST", "/upload", body)
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	w := httptest.NewRecorder()
+	s.ServeHTTP(w, req)
+	if w.Code != 413 || w.Body.String() != ErrUploadTooLarge.Message {
+		t.Errorf("expected 413 %q got %d %q", ErrUploadTooLarge.Message, w.Code, w.Body.String())
+	}
+	if body.n >= 1<<20 {
+		t.Errorf("expected the body to be read up to the limit, read %d bytes", body.n)
+	}
+}
+
+func TestUploadTempFilesRemoved(t *testing.T) {
+	tmp := filepath.Join(testDir, "tmp")
+	if err := os.MkdirAll(tmp, 0755); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Setenv("TMPDIR", os.Getenv("TMPDIR"))
+	os.Setenv("TMPDIR", tmp)
+
+	s := newTestServer(t)
+	s.Config.MaxMultipartMemory = 1024
+	s.Post("/upload", func(ctx *Context) string {
+		fh, err := ctx.FormFile("file")
+		if err != nil {
+			return err.Error()
+		}
+		return strconv.FormatInt(fh.Size, 10)
+	})
+	ts := httptest.NewServer(s)
+	defer ts.Close()
+
+	var buf bytes.Buffer
+	mw := multipart.NewWriter(&buf)
+	fw, _ := mw.CreateFormFile("file", "big.bin")
+	fw.Write(make([]byte, 1<<20))
+	mw.Close()
+	resp, err := http.Post(ts.URL+"/upload", mw.FormDataContentType(), &buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if string(body) != strconv.Itoa(1<<20) {
+		t.Fatalf("expected the upload size got %q", body)
+	}
+
+	left, err := filepath.Glob(filepath.Join(tmp, "multipart-*"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(left) > 0 {
+		t.Errorf("expected the temporary files to be removed, found %v", left)
+	}
+}
+
+func TestUploadMissingFile(t *testing.T) {
+	s := newTestServer(t)
+	s.Post("/upload", func(ctx *Context) error {
+		_, err := ctx.FormFile("file")
+		return err
+	})
+
+	var buf bytes.Buffer
+	mw := multipart.NewWriter(&buf)
+	mw.WriteField("title", "no file")
+	mw.Close()
+	req := httptest.NewRequest("POST", "/upload", &buf)
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	w := httptest.NewRecorder()
+	s.ServeHTTP(w, req)
+	if w.Code != 400 || w.Body.String() != ErrMissingFile.Message {
+		t.Errorf("missing file: expected 400 %q got %d %q", ErrMissingFile.Message, w.Code, w.Body.String())
+	}
+
+	if w := doRequest(s, "POST", "/upload", "title=form"); w.Code != 400 {
+		t.Errorf("form body: expected 400 got %d %q", w.Code, w.Body.String())
+	}
+}
@@ -0,0 +1,303 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// FieldError describes a field which failed a validation rule.
+type FieldError struct {
+	Field   string `json:"field"`
+	Rule    string `json:"rule"`
+	Param   string `json:"param,omitempty"`
+	Message string `json:"message"`
+}
+
+// ValidationErrors is the list of field errors returned by Validate.
+type ValidationErrors []FieldError
+
+func (e ValidationErrors) Error() string {
+	messages := make([]string, len(e))
+	for i, fe := range e {
+		messages[i] = fe.Message
+	}
+	return strings.Join(messages, "; ")
+}
+
+// ValidationFunc reports whether v satisfies a rule with the given param.
+type ValidationFunc func(v reflect.Value, param string) bool
+
+var (
+	validatorsLock sync.RWMutex
+	validators     = map[string]ValidationFunc{
+		"required": validateRequired,
+		"min":      validateMin,
+		"max":      validateMax,
+		"len":      validateLen,
+		"email":    validateEmail,
+		"oneof":    validateOneOf,
+	}
+)
+
+// RegisterValidation adds a rule usable in `validate` struct tags.
+func RegisterValidation(rule string, fn ValidationFunc) {
+	validatorsLock.Lock()
+	defer validatorsLock.Unlock()
+	validators[rule] = fn
+}
+
+// Validate checks the struct pointed to by v against the rules of its
+// `validate` tags, e.g. `validate:"required,min=1,max=64"`. Rules are
+// comma separated and their parameter follows an equal sign; oneof takes
+// a space separated list. A field tagged omitempty skips every rule when
+// empty. Nested structs are validated recursively.
+// It returns nil or a ValidationErrors.
+func Validate(v interface{}) error {
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Ptr {
+		if rv.IsNil() {
+			return errors.New("validate: nil pointer")
+		}
+		rv = rv.Elem()
+	}
+	if rv.Kind() != reflect.Struct {
+		return errors.New("validate: expected a struct")
+	}
+	var errs ValidationErrors
+	if err := validateStruct(rv, "", &errs); err != nil {
+		return err
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+func validateStruct(sv reflect.Value, prefix string, errs *ValidationErrors) error {
+	st := sv.Type()
+	for i := 0; i < st.NumField(); i++ {
+		field := st.Field(i)
+		if field.PkgPath != "" && !field.Anonymous {
+			continue
+		}
+		fv := sv.Field(i)
+		name := prefix + fieldName(field)
+
+		inner := fv
+		for inner.Kind() == reflect.Ptr && !inner.IsNil() {
+			inner = inner.Elem()
+		}
+		if inner.Kind() == reflect.Struct {
+			nested := name + "."
+			if field.Anonymous {
+				nested = prefix
+			}
+			if err := validateStruct(inner, nested, errs); err != nil {
+				return err
+			}
+		}
+
+		tag := field.Tag.Get("validate")
+		if tag == "" || tag == "-" {
+			continue
+		}
+		rules := strings.Split(tag, ",")
+		if isEmpty(fv) && contains(rules, "omitempty") {
+			continue
+		}
+		for _, rule := range rules {
+			if rule == "omitempty" {
+				continue
+			}
+			param := ""
+			if j := strings.IndexByte(rule, '='); j >= 0 {
+				rule, param = rule[:j], rule[j+1:]
+			}
+			validatorsLock.RLock()
+			fn, ok := validators[rule]
+			validatorsLock.RUnlock()
+			if !ok {
+				return fmt.Errorf("validate: unknown rule %q on field %s", rule, name)
+			}
+			if !fn(fv, param) {
+				*errs = append(*errs, FieldError{
+					Field:   name,
+					Rule:    rule,
+					Param:   param,
+					Message: validationMessage(name, rule, param),
+				})
+				break
+			}
+		}
+	}
+	return nil
+}
+
+// fieldName returns the name a field is known by in requests: its form
+// tag, its json tag, or the Go field name.
+func fieldName(field reflect.StructField) string {
+	for _, key := range []string{"form", "json"} {
+		tag := strings.Split(field.Tag.Get(key), ",")[0]
+		if tag != "" && tag != "-" {
+			return tag
+		}
+	}
+	return field.Name
+}
+
+func validationMessage(name string, rule string, param string) string {
+	switch rule {
+	case "required":
+		return name + " is required"
+	case "min":
+		return name + " must be at least " + param
+	case "max":
+		return name + " must be at most " + param
+	case "len":
+		return name + " must have length " + param
+	case "email":
+		return name + " must be a valid email address"
+	case "oneof":
+		return name + " must be one of " + param
+	}
+	if param != "" {
+		return name + " fails " + rule + "=" + param
+	}
+	return name + " fails " + rule
+}
+
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+func isEmpty(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.String, reflect.Slice, reflect.Map, reflect.Array:
+		return v.Len() == 0
+	case reflect.Ptr, reflect.Interface:
+		return v.IsNil()
+	case reflect.Bool:
+		return !v.Bool()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int() == 0
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return v.Uint() == 0
+	case reflect.Float32, reflect.Float64:
+		return v.Float() == 0
+	}
+	return false
+}
+
+func validateRequired(v reflect.Value, param string) bool {
+	return !isEmpty(v)
+}
+
+// size returns the value compared by min, max and len: the length of
+// strings and collections and the value of numbers.
+func size(v reflect.Value) (float64, bool) {
+	for v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return 0, false
+		}
+		v = v.Elem()
+	}
+	switch v.Kind() {
+	case reflect.String:
+		return float64(len([]rune(v.String()))), true
+	case reflect.Slice, reflect.Map, reflect.Array:
+		return float64(v.Len()), true
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(v.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(v.Uint()), true
+	case reflect.Float32, reflect.Float64:
+		return v.Float(), true
+	}
+	return 0, false
+}
+
+func compareSize(v reflect.Value, param string, ok func(n float64, p float64) bool) bool {
+	p, err := strconv.ParseFloat(param, 64)
+	if err != nil {
+		return false
+	}
+	n, valid := size(v)
+	return valid && ok(n, p)
+}
+
+func validateMin(v reflect.Value, param string) bool {
+	return compareSize(v, param, func(n float64, p float64) bool { return n >= p })
+}
+
+func validateMax(v reflect.Value, param string) bool {
+	return compareSize(v, param, func(n float64, p float64) bool { return n <= p })
+}
+
+func validateLen(v reflect.Value, param string) bool {
+	return compareSize(v, param, func(n float64, p float64) bool { return n == p })
+}
+
+var emailRegex = regexp.MustCompile(`^[a-zA-Z0-9.!#$%&'*+/=?^_{|}~-]+@[a-zA-Z0-9](?:[a-zA-Z0-9-]{0,61}[a-zA-Z0-9])?(?:\.[a-zA-Z0-9](?:[a-zA-Z0-9-]{0,61}[a-zA-Z0-9])?)+$`)
+
+func validateEmail(v reflect.Value, param string) bool {
+	return v.Kind() == reflect.String && emailRegex.MatchString(v.String())
+}
+
+func validateOneOf(v reflect.Value, param string) bool {
+	var s string
+	switch v.Kind() {
+	case reflect.String:
+		s = v.String()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		s = strconv.FormatInt(v.Int(), 10)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		s = strconv.FormatUint(v.Uint(), 10)
+	default:
+		return false
+	}
+	return contains(strings.Fields(param), s)
+}
+
+// Validate checks v against its `validate` struct tags, see Validate.
+func (ctx *Context) Validate(v interface{}) error {
+	return Validate(v)
+}
+
+// BindValid binds the request into v, then validates it.
+func (ctx *Context) BindValid(v interface{}) error {
+	if err := ctx.Bind(v); err != nil {
+		return err
+	}
+	return Validate(v)
+}
+
+// MustBind binds and validates the request into v. On failure it answers
+// 400 Bad Request with a JSON body listing the errors, like
+// {"error":"...","fields":[{"field":"name","rule":"required",...}]},
+// and returns false.
+func (ctx *Context) MustBind(v interface{}) bool {
+	err := ctx.BindValid(v)
+	if err == nil {
+		return true
+	}
+	body := struct {
+		Error  string           `json:"error"`
+		Fields ValidationErrors `json:"fields,omitempty"`
+	}{Error: err.Error()}
+	if errs, ok := err.(ValidationErrors); ok {
+		body.Error = "validation failed"
+		body.Fields = errs
+	}
+	ctx.JSON(400, body)
+	return false
+}
@@ -0,0 +1,64 @@
+package server
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+type signup struct {
+	Name  string `form:"name" validate:"required,min=2,max=8"`
+	Email string `form:"email" validate:"required,email"`
+	Role  string `form:"role" validate:"omitempty,oneof=admin user"`
+	Age   int    `form:"age" validate:"min=18"`
+}
+
+func TestValidate(t *testing.T) {
+	err := Validate(&signup{Name: "bob", Email: "bob@example.com", Age: 20})
+	if err != nil {
+		t.Errorf("expected valid got %v", err)
+	}
+
+	err = Validate(&signup{Name: "b", Email: "bob", Role: "root", Age: 3})
+	errs, ok := err.(ValidationErrors)
+	if !ok {
+		t.Fatalf("expected ValidationErrors got %v", err)
+	}
+	var fields []string
+	for _, fe := range errs {
+		fields = append(fields, fe.Field+":"+fe.Rule)
+	}
+	if got := strings.Join(fields, ","); got != "name:min,email:email,role:oneof,age:min" {
+		t.Errorf("unexpected errors %s", got)
+	}
+}
+
+func TestMustBind(t *testing.T) {
+	s := newTestServer(t)
+	s.Post("/signup", func(ctx *Context) string {
+		var form signup
+		if !ctx.MustBind(&form) {
+			return ""
+		}
+		return "welcome " + form.Name
+	})
+
+	if w := doRequest(s, "POST", "/signup", "name=bob&email=bob@example.com&age=30"); w.Body.String() != "welcome bob" {
+		t.Errorf("unexpected body %q", w.Body.String())
+	}
+
+	w := doRequest(s, "POST", "/signup", "email=bob@example.com&age=30")
+	if w.Code != 400 {
+		t.Fatalf("expected 400 got %d", w.Code)
+	}
+	var body struct {
+		Error  string
+		Fields []FieldError
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+		t.Fatal(err)
+	}
+	if len(body.Fields) != 1 || body.Fields[0].Field != "name" || body.Fields[0].Rule != "required" {
+		t.Errorf("unexpected error body %s", w.Body.String())
+	}
+}
@@ -4,6 +4,7 @@ package server
 
 import (
 	"bytes"
+	"context"
 	"crypto/hmac"
 	"crypto/sha1"
 	"crypto/tls"
@@ -13,6 +14,7 @@ import (
 	"io/ioutil"
 	"mime"
 	"net/http"
+	"net/url"
 	"os"
 	"path"
 	"reflect"
@@ -30,6 +32,25 @@ type Context struct {
 	Params  map[string]string
 	Server  *Server
 	http.ResponseWriter
+
+	pathParams map[string]string
+	routes     methodRoutes
+	query      url.Values
+	formParsed bool
+	// body is the request body when MaxBodySize limits it, tooLarge is
+	// set once a multipart body broke a size limit while being parsed
+	body       *limitedBody
+	tooLarge   bool
+	sseStarted bool
+	requestID  string
+	csrfToken  string
+	csrfField  string
+}
+
+// PathParam returns the value captured by the `:name` or `*name` segment
+// of the matched route, or an empty string if there is none.
+func (ctx *Context) PathParam(name string) string {
+	return ctx.pathParams[name]
 }
 
 // WriteString writes string data into the response object.
@@ -126,6 +147,12 @@ func (ctx *Context) SetSecureCookie(name string, val string, age int64) {
 		ctx.Server.Logger.Print("Secret Key for secure cookies has not been set. Please assign a cookie secret to web.Config.CookieSecret.")
 		return
 	}
+	ctx.SetCookie(NewCookie(name, ctx.signCookieValue(val), age))
+}
+
+// signCookieValue encodes val for a secure cookie, signed with the
+// cookie secret.
+func (ctx *Context) signCookieValue(val string) string {
 	var buf bytes.Buffer
 	encoder := base64.NewEncoder(base64.StdEncoding, &buf)
 	encoder.Write([]byte(val))
@@ -134,8 +161,7 @@ func (ctx *Context) SetSecureCookie(name string, val string, age int64) {
 	vb := buf.Bytes()
 	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
 	sig := getCookieSig(ctx.Server.Config.CookieSecret, vb, timestamp)
-	cookie := strings.Join([]string{vs, timestamp, sig}, "|")
-	ctx.SetCookie(NewCookie(name, cookie, age))
+	return strings.Join([]string{vs, timestamp, sig}, "|")
 }
 
 func (ctx *Context) GetSecureCookie(name string) (string, bool) {
@@ -145,6 +171,9 @@ func (ctx *Context) GetSecureCookie(name string) (string, bool) {
 		}
 
 		parts := strings.SplitN(cookie.Value, "|", 3)
+		if len(parts) != 3 {
+			return "", false
+		}
 
 		val := parts[0]
 		timestamp := parts[1]
@@ -202,13 +231,30 @@ func Process(c http.ResponseWriter, req *http.Request) {
 }
 
 // Run starts the web application and serves HTTP requests for the main server.
-func Run(addr string) {
-	mainServer.Run(addr)
+func Run(addr string) error {
+	return mainServer.Run(addr)
+}
+
+// RunContext serves HTTP requests for the main server until ctx is done,
+// then shuts it down gracefully.
+func RunContext(ctx context.Context, addr string) error {
+	return mainServer.RunContext(ctx, addr)
 }
 
 // RunTLS starts the web application and serves HTTPS requests for the main server.
-func RunTLS(addr string, config *tls.Config) {
-	mainServer.RunTLS(addr, config)
+func RunTLS(addr string, config *tls.Config) error {
+	return mainServer.RunTLS(addr, config)
+}
+
+// RunTLSFiles serves HTTPS requests for the main server, reloading the
+// certificate when the files change.
+func RunTLSFiles(addr string, certFile string, keyFile string) error {
+	return mainServer.RunTLSFiles(addr, certFile, keyFile)
+}
+
+// Shutdown gracefully stops the main server.
+func Shutdown(ctx context.Context) error {
+	return mainServer.Shutdown(ctx)
 }
 
 // Close stops the main server.
@@ -217,16 +263,99 @@ func Close() {
 }
 
 func AddFilter(route string, handler FilerFun) {
-	mainServer.addFilter(route, handler)
+	mainServer.AddFilter(route, handler)
+}
+
+func AddRoute(route string, handler interface{}, middlewares ...Middleware) {
+	mainServer.AddRoute(route, handler, middlewares...)
+}
+
+// Static mounts fs under the URL prefix of the main server.
+func Static(prefix string, fs http.FileSystem) *StaticMount {
+	return mainServer.Static(prefix, fs)
+}
+
+// WebSocket adds a WebSocket route to the main server.
+func WebSocket(route string, handler WebSocketHandler, middlewares ...Middleware) {
+	mainServer.WebSocket(route, handler, middlewares...)
+}
+
+// CORS enables Cross-Origin Resource Sharing for the routes of the main
+// server.
+func CORS(config CORSConfig) {
+	mainServer.CORS(config)
+}
+
+// ErrorHandler registers fn to write the responses with status code for
+// the main server.
+func ErrorHandler(code int, fn ErrorHandlerFunc) {
+	mainServer.ErrorHandler(code, fn)
+}
+
+// LoadTemplates parses the templates of dir with the extension ext for the
+// main server.
+func LoadTemplates(dir, ext string) error {
+	return mainServer.LoadTemplates(dir, ext)
+}
+
+// AddTemplateFunc makes fn available to the templates of the main server.
+func AddTemplateFunc(name string, fn interface{}) {
+	mainServer.AddTemplateFunc(name, fn)
+}
+
+// Use appends middlewares wrapping every request of the main server.
+func Use(middlewares ...Middleware) {
+	mainServer.Use(middlewares...)
+}
+
+// Match adds a handler for the given http method for the main server.
+func Match(method string, route string, handler interface{}, middlewares ...Middleware) {
+	mainServer.Match(method, route, handler, middlewares...)
+}
+
+// Get adds a handler for the 'GET' http method for the main server.
+func Get(route string, handler interface{}, middlewares ...Middleware) {
+	mainServer.Get(route, handler, middlewares...)
+}
+
+// Post adds a handler for the 'POST' http method for the main server.
+func Post(route string, handler interface{}, middlewares ...Middleware) {
+	mainServer.Post(route, handler, middlewares...)
+}
+
+// Put adds a handler for the 'PUT' http method for the main server.
+func Put(route string, handler interface{}, middlewares ...Middleware) {
+	mainServer.Put(route, handler, middlewares...)
+}
+
+// Delete adds a handler for the 'DELETE' http method for the main server.
+func Delete(route string, handler interface{}, middlewares ...Middleware) {
+	mainServer.Delete(route, handler, middlewares...)
+}
+
+// Patch adds a handler for the 'PATCH' http method for the main server.
+func Patch(route string, handler interface{}, middlewares ...Middleware) {
+	mainServer.Patch(route, handler, middlewares...)
+}
+
+// Options adds a handler for the 'OPTIONS' http method for the main server.
+func Options(route string, handler interface{}, middlewares ...Middleware) {
+	mainServer.Options(route, handler, middlewares...)
+}
+
+// Any adds a handler for every http method for the main server.
+func Any(route string, handler interface{}, middlewares ...Middleware) {
+	mainServer.Any(route, handler, middlewares...)
 }
 
-func AddRoute(route string, handler interface{}) {
-	mainServer.addRoute(route, handler)
+// Group returns a sub-router of the main server mounted under prefix.
+func Group(prefix string, filters ...FilerFun) *RouteGroup {
+	return mainServer.Group(prefix, filters...)
 }
 
 //Adds a custom handler. Only for webserver mode. Will have no effect when running as FCGI or SCGI.
-func Handler(route string, httpHandler http.Handler) {
-	mainServer.Handler(route, httpHandler)
+func Handler(route string, httpHandler http.Handler, middlewares ...Middleware) {
+	mainServer.Handler(route, httpHandler, middlewares...)
 }
 
 // SetLogger sets the logger for the main server.
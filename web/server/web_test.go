@@ -1,3 +1,10 @@
+//go:build ignore
+// +build ignore
+
+// These tests were written for the SCGI server and the regex routes of
+// web.go, which were removed; they are kept out of the build until they
+// are ported to the current router.
+
 package server
 
 import (
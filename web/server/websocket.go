@@ -0,0 +1,238 @@
+package server
+
+import (
+	"github.com/gorilla/websocket"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Message types of WebSocket data frames.
+const (
+	TextMessage   = websocket.TextMessage
+	BinaryMessage = websocket.BinaryMessage
+)
+
+const (
+	defaultWebSocketReadLimit    = 1 << 20
+	defaultWebSocketPingInterval = 30 * time.Second
+	webSocketWriteWait           = 10 * time.Second
+)
+
+// WebSocketHandler serves an upgraded WebSocket connection. The
+// connection is closed when the handler returns.
+type WebSocketHandler func(*Context, *Conn)
+
+// Conn is a WebSocket connection. Its write methods may be called
+// concurrently, e.g. by a handler and a Hub broadcast, but a single
+// goroutine may read.
+type Conn struct {
+	ws *websocket.Conn
+	mu sync.Mutex
+}
+
+// ReadMessage reads the next message, returning its type, TextMessage or
+// BinaryMessage, and its data.
+func (c *Conn) ReadMessage() (int, []byte, error) {
+	return c.ws.ReadMessage()
+}
+
+// ReadJSON reads the next message and decodes it as JSON into v.
+func (c *Conn) ReadJSON(v interface{}) error {
+	return c.ws.ReadJSON(v)
+}
+
+// Discard reads and drops the messages of the peer until the connection
+// fails, returning the error. Handlers that only write, such as the ones
+// of Hub broadcasts, call it to keep answering pings and notice when the
+// peer is gone.
+func (c *Conn) Discard() error {
+	for {
+		if _, _, err := c.ws.NextReader(); err != nil {
+			return err
+		}
+	}
+}
+
+// WriteMessage writes a message of type mt, TextMessage or BinaryMessage.
+func (c *Conn) WriteMessage(mt int, data []byte) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.ws.SetWriteDeadline(time.Now().Add(webSocketWriteWait))
+	return c.ws.WriteMessage(mt, data)
+}
+
+// WriteJSON writes v encoded as JSON in a text message.
+func (c *Conn) WriteJSON(v interface{}) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.ws.SetWriteDeadline(time.Now().Add(webSocketWriteWait))
+	return c.ws.WriteJSON(v)
+}
+
+// Close closes the connection without a close message.
+func (c *Conn) Close() error {
+	return c.ws.Close()
+}
+
+// RemoteAddr returns the address of the peer.
+func (c *Conn) RemoteAddr() net.Addr {
+	return c.ws.RemoteAddr()
+}
+
+// WebSocket upgrades GET requests on route to WebSocket connections served
+// by handler. The route goes through the filters and middlewares like any
+// other, so sessions and authentication apply before the upgrade.
+// Origins are checked against Config.WebSocketOrigins, messages larger
+// than Config.WebSocketReadLimit are refused and the peer is pinged every
+// Config.WebSocketPingInterval. Its pongs are handled while the handler
+// reads, so handlers must keep reading until the connection fails, with
+// Conn.Discard when they only write; reading then fails once the peer
+// stops answering for two intervals.
+func (s *Server) WebSocket(route string, handler WebSocketHandler, middlewares ...Middleware) {
+	s.addRoute(route, "GET", s.upgradeHandler(handler), middlewares)
+}
+
+// WebSocket adds a WebSocket route to the group, see Server.WebSocket.
+func (g *RouteGroup) WebSocket(route string, handler WebSocketHandler, middlewares ...Middleware) {
+	g.addRoute(route, "GET", g.server.upgradeHandler(handler), middlewares)
+}
+
+func (s *Server) upgradeHandler(handler WebSocketHandler) func(*Context) {
+	return func(ctx *Context) {
+		config := ctx.Server.Config
+		upgrader := websocket.Upgrader{}
+		if len(config.WebSocketOrigins) > 0 {
+			upgrader.CheckOrigin = func(r *http.Request) bool {
+				return matchOrigin(r.Header.Get("Origin"), config.WebSocketOrigins)
+			}
+		}
+		ws, err := upgrader.Upgrade(ctx.ResponseWriter, ctx.Request, nil)
+		if err != nil {
+			// the upgrader has already answered with an HTTP error
+			ctx.Logger().Error("websocket upgrade: %v", err)
+			return
+		}
+		conn := &Conn{ws: ws}
+		defer conn.Close()
+
+		readLimit := config.WebSocketReadLimit
+		if readLimit <= 0 {
+			readLimit = defaultWebSocketReadLimit
+		}
+		ws.SetReadLimit(readLimit)
+
+		interval := config.WebSocketPingInterval
+		if interval <= 0 {
+			interval = defaultWebSocketPingInterval
+		}
+		pongWait := interval * 2
+		ws.SetReadDeadline(time.Now().Add(pongWait))
+		ws.SetPongHandler(func(string) error {
+			return ws.SetReadDeadline(time.Now().Add(pongWait))
+		})
+
+		done := make(chan struct{})
+		defer close(done)
+		go func() {
+			ticker := time.NewTicker(interval)
+			defer ticker.Stop()
+			for {
+				select {
+				case <-done:
+					return
+				case <-ticker.C:
+					if err := ws.WriteControl(websocket.PingMessage, nil, time.Now().Add(webSocketWriteWait)); err != nil {
+						return
+					}
+				}
+			}
+		}()
+
+		handler(ctx, conn)
+	}
+}
+
+// matchOrigin reports whether origin is one of the allowed origins.
+// An allowed origin may contain a single wildcard, like
+// "https://*.example.com", and "*" allows any origin.
+func matchOrigin(origin string, allowed []string) bool {
+	if origin == "" {
+		return false
+	}
+	origin = strings.ToLower(origin)
+	for _, a := range allowed {
+		a = strings.ToLower(a)
+		if a == "*" || a == origin {
+			return true
+		}
+		if i := strings.IndexByte(a, '*'); i >= 0 {
+			prefix, suffix := a[:i], a[i+1:]
+			if len(origin) > len(prefix)+len(suffix) && strings.HasPrefix(origin, prefix) && strings.HasSuffix(origin, suffix) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Hub is a set of WebSocket connections messages can be broadcast to.
+type Hub struct {
+	mu    sync.RWMutex
+	conns map[*Conn]struct{}
+}
+
+// NewHub returns an empty Hub.
+func NewHub() *Hub {
+	return &Hub{conns: map[*Conn]struct{}{}}
+}
+
+// Add registers c in the hub.
+func (h *Hub) Add(c *Conn) {
+	h.mu.Lock()
+	h.conns[c] = struct{}{}
+	h.mu.Unlock()
+}
+
+// Remove unregisters c from the hub.
+func (h *Hub) Remove(c *Conn) {
+	h.mu.Lock()
+	delete(h.conns, c)
+	h.mu.Unlock()
+}
+
+// Len returns the number of connections in the hub.
+func (h *Hub) Len() int {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return len(h.conns)
+}
+
+// Broadcast sends a message of type mt to every connection of the hub.
+// Connections failing to receive it are closed and removed.
+func (h *Hub) Broadcast(mt int, data []byte) {
+	h.broadcast(func(c *Conn) error { return c.WriteMessage(mt, data) })
+}
+
+// BroadcastJSON sends v encoded as JSON to every connection of the hub.
+func (h *Hub) BroadcastJSON(v interface{}) {
+	h.broadcast(func(c *Conn) error { return c.WriteJSON(v) })
+}
+
+func (h *Hub) broadcast(send func(*Conn) error) {
+	h.mu.RLock()
+	conns := make([]*Conn, 0, len(h.conns))
+	for c := range h.conns {
+		conns = append(conns, c)
+	}
+	h.mu.RUnlock()
+
+	for _, c := range conns {
+		if err := send(c); err != nil {
+			h.Remove(c)
+			c.Close()
+		}
+	}
+}
@@ -0,0 +1,97 @@
+package server
+
+import (
+	"github.com/gorilla/websocket"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWebSocket(t *testing.T) {
+	s := newTestServer(t)
+	s.Config.WebSocketOrigins = []string{"https://*.example.com"}
+	hub := NewHub()
+	s.WebSocket("/ws/:room", func(ctx *Context, conn *Conn) {
+		hub.Add(conn)
+		defer hub.Remove(conn)
+		for {
+			_, msg, err := conn.ReadMessage()
+			if err != nil {
+				return
+			}
+			hub.Broadcast(TextMessage, []byte(ctx.PathParam("room")+": "+string(msg)))
+		}
+	})
+	ts := httptest.NewServer(s)
+	defer ts.Close()
+	url := "ws" + strings.TrimPrefix(ts.URL, "http") + "/ws/lobby"
+
+	header := http.Header{"Origin": {"https://app.example.com"}}
+	c1, _, err := websocket.DefaultDialer.Dial(url, header)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c1.Close()
+	c2, _, err := websocket.DefaultDialer.Dial(url, header)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c2.Close()
+
+	// wait for both connections to join the hub
+	for i := 0; i < 100 && hub.Len() < 2; i++ {
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	c1.WriteMessage(TextMessage, []byte("hi"))
+	for _, c := range []*websocket.Conn{c1, c2} {
+		_, msg, err := c.ReadMessage()
+		if err != nil || string(msg) != "lobby: hi" {
+			t.Errorf("expected broadcast got %q %v", msg, err)
+		}
+	}
+
+	_, resp, err := websocket.DefaultDialer.Dial(url, http.Header{"Origin": {"https://evil.com"}})
+	if err == nil || resp == nil || resp.StatusCode != 403 {
+		t.Errorf("expected forbidden origin to be refused, got %v", err)
+	}
+}
+
+func TestWebSocketDiscard(t *testing.T) {
+	s := newTestServer(t)
+	s.Config.WebSocketPingInterval = 50 * time.Millisecond
+	hub := NewHub()
+	done := make(chan error, 1)
+	s.WebSocket("/feed", func(ctx *Context, conn *Conn) {
+		hub.Add(conn)
+		defer hub.Remove(conn)
+		done <- conn.Discard()
+	})
+	ts := httptest.NewServer(s)
+	defer ts.Close()
+
+	c, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(ts.URL, "http")+"/feed", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	for i := 0; i < 100 && hub.Len() < 1; i++ {
+		time.Sleep(5 * time.Millisecond)
+	}
+	hub.Broadcast(TextMessage, []byte("news"))
+	if _, msg, err := c.ReadMessage(); err != nil || string(msg) != "news" {
+		t.Fatalf("expected broadcast got %q %v", msg, err)
+	}
+
+	// the client stops reading, so it stops answering the pings
+	select {
+	case err := <-done:
+		if err == nil {
+			t.Error("expected Discard to fail")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("a peer not answering the pings was not dropped")
+	}
+}